@@ -16,9 +16,14 @@ import (
 )
 
 type appConfig struct {
-	debug  bool
-	port   int
-	unsafe bool
+	debug   bool
+	port    int
+	unsafe  bool
+	force   bool
+	future  bool
+	expired bool
+	drafts  bool
+	links   bool
 }
 
 const (
@@ -28,6 +33,7 @@ const (
 	outputDir   = "public"
 	configFile  = "site.yaml"
 	storyFile   = "site.biff"
+	cacheDir    = ".nibl-cache"
 )
 
 func main() {
@@ -36,6 +42,11 @@ func main() {
 	flag.BoolVar(&appCfg.debug, "debug", false, "Enable debug mode for verbose error output.")
 	flag.IntVar(&appCfg.port, "port", 1313, "Port for the local development server.")
 	flag.BoolVar(&appCfg.unsafe, "unsafe", false, "Disable HTML sanitization. Allows all raw HTML.")
+	flag.BoolVar(&appCfg.force, "force", false, "Re-render every page instead of reusing unchanged ones.")
+	flag.BoolVar(&appCfg.future, "future", false, "Include content whose publishDate is in the future.")
+	flag.BoolVar(&appCfg.expired, "expired", false, "Include content whose expiryDate has passed.")
+	flag.BoolVar(&appCfg.drafts, "drafts", false, "Include content marked as draft, e.g. to preview it with serve.")
+	flag.BoolVar(&appCfg.links, "check-links", false, "Check internal links and anchors after building; fail if any are broken.")
 	flag.Usage = printHelp
 	flag.Parse()
 
@@ -53,8 +64,15 @@ func run(appCfg appConfig) error {
 	}
 
 	opts := builder.BuildOptions{
-		Unsafe: appCfg.unsafe,
-		Debug:  appCfg.debug,
+		Unsafe:       appCfg.unsafe,
+		Debug:        appCfg.debug,
+		Force:        appCfg.force,
+		BuildFuture:  appCfg.future,
+		BuildExpired: appCfg.expired,
+		BuildDrafts:  appCfg.drafts,
+		ConfigFile:   configFile,
+		TemplateDir:  templateDir,
+		CacheDir:     cacheDir,
 	}
 
 	switch args[0] {
@@ -73,6 +91,9 @@ func run(appCfg appConfig) error {
 			return fmt.Errorf("site generation failed: %w", err)
 		}
 		fmt.Printf("✅ Success! Generated %d pages.\n", pageCount)
+		if appCfg.links {
+			return checkLinks()
+		}
 		return nil
 
 	case "story":
@@ -107,15 +128,39 @@ func run(appCfg appConfig) error {
 
 		// Clean the public directory only when doing a full build
 		opts.CleanDestination = !(*contentOnly)
-		return handleStoryCommand(*inputFile, finalOutputDir, *contentOnly, opts)
+		if err := handleStoryCommand(*inputFile, finalOutputDir, *contentOnly, opts); err != nil {
+			return err
+		}
+		if appCfg.links && !*contentOnly {
+			return checkLinks()
+		}
+		return nil
 
 	case "serve":
 		// The build function for `serve` must do a full build using default paths.
 		buildFunc := func(buildOpts builder.BuildOptions) error {
-			return runFullBuild(buildOpts)
+			if err := runFullBuild(buildOpts); err != nil {
+				return err
+			}
+			// Broken links are reported, but the rebuilt site is still served.
+			if appCfg.links {
+				if err := checkLinks(); err != nil {
+					fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+				}
+			}
+			return nil
 		}
 		return server.Run(appCfg.port, buildFunc, opts)
 
+	case "check":
+		if len(args) < 2 {
+			return fmt.Errorf("check needs something to check, e.g. `nibl check links`")
+		}
+		if args[1] != "links" {
+			return fmt.Errorf("unknown check %q, the only one is `nibl check links`", args[1])
+		}
+		return checkLinks()
+
 	case "new":
 		if len(args) < 3 {
 			flag.Usage()
@@ -208,6 +253,24 @@ func runFullBuild(opts builder.BuildOptions) error {
 	return nil
 }
 
+// checkLinks verifies every internal link and #fragment in the generated
+// site, listing the broken ones by page.
+func checkLinks() error {
+	fmt.Println("--- Checking links ---")
+	broken, err := builder.CheckLinks(outputDir, getSiteConfig())
+	if err != nil {
+		return fmt.Errorf("link check failed: %w", err)
+	}
+	if len(broken) == 0 {
+		fmt.Println("🔗 All links resolve.")
+		return nil
+	}
+	for _, link := range broken {
+		fmt.Printf("  %s\n", link)
+	}
+	return fmt.Errorf("found %d broken link(s)", len(broken))
+}
+
 func getSiteConfig() config.SiteConfig {
 	siteCfg, err := config.LoadSiteConfig(configFile)
 	if err != nil {
@@ -228,10 +291,10 @@ func printHelp() {
 	fmt.Println("  story [options]    Compile .biff file and build site. Use 'nibl story -h' for options.")
 	fmt.Println("  gen                Generate site from existing content")
 	fmt.Println("  serve              Run a local dev server with auto-rebuild")
+	fmt.Println("  check links        Check the links and anchors of the generated site")
 	fmt.Println("  new site <name>    Create a new site scaffold")
 	fmt.Println("  new <type> <title> Create new content from archetype")
 	fmt.Println()
 	fmt.Println("Global Flags:")
 	flag.PrintDefaults()
 }
-
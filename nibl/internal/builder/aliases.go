@@ -0,0 +1,113 @@
+// internal/builder/aliases.go
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"nibl/internal/util"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// aliasStub is written at every alias of a page. It sends readers on to
+// the page and tells search engines where the content lives now.
+var aliasStub = template.Must(template.New("alias").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{ .Title }}</title>
+  <link rel="canonical" href="{{ .Canonical }}">
+  <meta name="robots" content="noindex">
+  <meta http-equiv="refresh" content="0; url={{ .Target }}">
+</head>
+<body>
+  <p>This page has moved to <a href="{{ .Target }}">{{ .Title }}</a>.</p>
+</body>
+</html>
+`))
+
+// alias is a redirect stub written for a page at one of its old URLs.
+type alias struct {
+	output string // Slash-separated output path relative to the output dir
+	page   *Page
+}
+
+// resolveAliases works out where the aliases of the published pages are
+// written. An alias may not replace a page or another alias; taken holds
+// the outputs already in use and gains the aliases.
+func resolveAliases(pages []*Page, pretty bool, taken map[string]string) ([]alias, error) {
+	var aliases []alias
+	for _, page := range pages {
+		for _, name := range page.Aliases {
+			output, err := aliasOutput(name, pretty)
+			if err != nil {
+				return nil, fmt.Errorf("invalid alias %q in %s: %w", name, page.sourcePath, err)
+			}
+			if owner, ok := taken[output]; ok {
+				return nil, fmt.Errorf("alias %q in %s would replace %s", name, page.sourcePath, owner)
+			}
+			taken[output] = "the alias " + output + " of " + page.sourcePath
+			aliases = append(aliases, alias{output: output, page: page})
+		}
+	}
+	return aliases, nil
+}
+
+// writeAliases writes a redirect stub at each alias and returns their
+// output paths.
+func writeAliases(outputDir string, aliases []alias, site SiteData) ([]string, error) {
+	var written []string
+	for _, a := range aliases {
+		page := a.page
+		// Like every other link, the target is relative to the stub, so it
+		// also works without an absolute baseurl.
+		target := util.ComputeBaseHref(filepath.FromSlash(a.output)) + page.URL
+		if target == "" {
+			target = "./"
+		}
+		canonical := target
+		if util.IsAbsoluteURL(site.BaseURL) {
+			canonical = util.AbsURL(site.BaseURL, page.URL)
+		}
+		var buf bytes.Buffer
+		if err := aliasStub.Execute(&buf, map[string]string{
+			"Title":     page.Title,
+			"Target":    target,
+			"Canonical": canonical,
+		}); err != nil {
+			return nil, err
+		}
+		dest := filepath.Join(outputDir, filepath.FromSlash(a.output))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(dest, buf.Bytes(), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write alias %s: %w", a.output, err)
+		}
+		written = append(written, a.output)
+	}
+	sort.Strings(written)
+	return written, nil
+}
+
+// aliasOutput returns the file an alias is written to. Aliases follow the
+// rules of the `url:` key: "old/" and "old.html" are used as they are,
+// while "old" follows the site's pretty_urls setting.
+func aliasOutput(alias string, pretty bool) (string, error) {
+	clean := strings.TrimPrefix(path.Clean("/"+alias), "/")
+	switch {
+	case clean == "":
+		return "", fmt.Errorf("an alias cannot be the site root")
+	case strings.HasSuffix(alias, "/"):
+		return path.Join(clean, "index.html"), nil
+	case path.Ext(clean) == ".html":
+		return clean, nil
+	case pretty:
+		return clean + "/index.html", nil
+	}
+	return clean + ".html", nil
+}
@@ -0,0 +1,151 @@
+// internal/builder/assets.go
+package builder
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"nibl/internal/config"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// assetManifestFile maps every processed asset to its published name.
+const assetManifestFile = "assets.json"
+
+// asset is a CSS or JS file from static/ after minification and fingerprinting.
+type asset struct {
+	Path      string `json:"path"`                // Published path, e.g. "css/style.1a2b3c4d5e.css"
+	Integrity string `json:"integrity,omitempty"` // Subresource Integrity hash, e.g. "sha384-..."
+
+	data []byte
+}
+
+// assetSet holds the processed assets of a build, keyed by their path in static/.
+type assetSet map[string]*asset
+
+// loadAssets runs the opt-in asset pipeline over the CSS and JS files in
+// static/: they are minified and/or renamed after a hash of their content.
+// Nothing is written yet, so the result can be fingerprinted before the
+// output directory is cleaned.
+func loadAssets(staticDir string, site config.SiteConfig) (assetSet, error) {
+	cfg := site.Assets
+	if !cfg.Minify && !cfg.Fingerprint && !cfg.Integrity {
+		return nil, nil
+	}
+	if _, err := os.Stat(staticDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	selection, err := newStaticSelection(site.Static)
+	if err != nil {
+		return nil, err
+	}
+
+	assets := make(assetSet)
+	err = walkStatic(staticDir, func(rel, p string, info os.FileInfo) error {
+		ext := path.Ext(rel)
+		if ext != ".css" && ext != ".js" {
+			return nil
+		}
+		if _, ok := selection.selects(rel); !ok {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if cfg.Minify && !strings.HasSuffix(rel, ".min"+ext) {
+			if ext == ".css" {
+				data = minifyCSS(data)
+			} else {
+				data = minifyJS(data)
+			}
+		}
+
+		a := &asset{Path: rel, data: data}
+		if cfg.Fingerprint {
+			sum := sha256.Sum256(data)
+			a.Path = strings.TrimSuffix(rel, ext) + "." + hex.EncodeToString(sum[:])[:10] + ext
+		}
+		if cfg.Integrity {
+			sum := sha512.Sum384(data)
+			a.Integrity = "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+		}
+		assets[rel] = a
+		return nil
+	})
+	return assets, err
+}
+
+// fingerprint summarises the published names, so pages that link assets
+// are rebuilt whenever one of them changes.
+func (as assetSet) fingerprint() string {
+	if len(as) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(as) // Map keys are marshalled in sorted order
+	return hashBytes(data)
+}
+
+// write saves the processed assets and the asset manifest, and returns the
+// outputs it wrote.
+func (as assetSet) write(outputDir string) ([]string, error) {
+	if len(as) == 0 {
+		return nil, nil
+	}
+	var written []string
+	for _, a := range as {
+		dest := filepath.Join(outputDir, filepath.FromSlash(a.Path))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(dest, a.data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write asset %s: %w", a.Path, err)
+		}
+		written = append(written, a.Path)
+	}
+	data, err := json.MarshalIndent(as, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, assetManifestFile), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	sort.Strings(written)
+	return append(written, assetManifestFile), nil
+}
+
+// handled returns the static files the pipeline publishes itself.
+func (as assetSet) handled() map[string]bool {
+	set := make(map[string]bool, len(as))
+	for rel := range as {
+		set[rel] = true
+	}
+	return set
+}
+
+// path resolves a static path such as "css/style.css" to its published
+// name. Files outside the pipeline are returned unchanged.
+func (as assetSet) path(rel string) string {
+	if a, ok := as[strings.TrimPrefix(rel, "/")]; ok {
+		return a.Path
+	}
+	return rel
+}
+
+// integrity returns the integrity and crossorigin attributes of an asset,
+// or nothing when Subresource Integrity is off.
+func (as assetSet) integrity(rel string) template.HTMLAttr {
+	a, ok := as[strings.TrimPrefix(rel, "/")]
+	if !ok || a.Integrity == "" {
+		return ""
+	}
+	return template.HTMLAttr(fmt.Sprintf(` integrity="%s" crossorigin="anonymous"`, a.Integrity))
+}
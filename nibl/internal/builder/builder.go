@@ -4,28 +4,53 @@ package builder
 import (
 	"fmt"
 	"html/template"
-	"io"
+	"net/url"
 	"nibl/internal/config"
 	"nibl/internal/util"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
-	"unicode/utf8"
+	"sync"
+	"time"
 )
 
 type BuildOptions struct {
 	CleanDestination bool
 	Unsafe           bool
 	Debug            bool
+	Force            bool   // Re-render every page; the manifest only serves to remove stale outputs
+	BuildFuture      bool   // Include pages whose publishDate is in the future
+	BuildExpired     bool   // Include pages whose expiryDate has passed
+	BuildDrafts      bool   // Include pages marked `draft: true`
+	ConfigFile       string // Path to site.yaml, hashed for incremental builds
+	TemplateDir      string // Root of all themes, hashed for incremental builds
+	CacheDir         string // Keeps processed images between builds; none if empty
 }
 
 // BuildSite processes content files, renders them into HTML pages, and copies static assets.
-func BuildSite(outputDir, contentDir, staticDir string, site config.SiteConfig, tmpl *template.Template, opts BuildOptions) (int, error) {
+func BuildSite(outputDir, contentDir, staticDir string, site config.SiteConfig, theme *Theme, opts BuildOptions) (int, error) {
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return 0, err
 	}
 
-	if opts.CleanDestination {
+	current, err := newManifest(site, opts)
+	if err != nil {
+		return 0, err
+	}
+	// The previous manifest is read even with --force, so outputs that are
+	// no longer produced are still removed.
+	previous := loadManifest(outputDir)
+	assets, err := loadAssets(staticDir, site)
+	if err != nil {
+		return 0, fmt.Errorf("failed to process assets: %w", err)
+	}
+	current.Assets = assets.fingerprint()
+	// Pages can only be reused when nothing they all share has changed.
+	incremental := !opts.Force && current.sharedInputsMatch(previous) && previous.imagesUnchanged(staticDir)
+
+	if opts.CleanDestination && !incremental {
 		fmt.Println("Cleaning destination directory...")
 		entries, err := os.ReadDir(outputDir)
 		if err != nil {
@@ -38,154 +63,563 @@ func BuildSite(outputDir, contentDir, staticDir string, site config.SiteConfig,
 		}
 	}
 
-	pagesGenerated := 0
-	if err := filepath.Walk(contentDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	languages, err := siteLanguages(site)
+	if err != nil {
+		return 0, err
+	}
+	pages, err := loadPages(contentDir)
+	if err != nil {
+		return 0, err
+	}
+	assignLanguages(pages, languages)
+
+	now := time.Now()
+	var published []*Page
+	drafts := 0
+	for _, page := range pages {
+		if page.Draft && alwaysPublished(page, site.AlwaysPublish) {
+			page.Draft = false
 		}
-		if info.IsDir() {
-			return nil
+		skip := page.Draft && !opts.BuildDrafts
+		skip = skip || (!opts.BuildFuture && page.isScheduled(now))
+		skip = skip || (!opts.BuildExpired && page.isExpired(now))
+		if skip {
+			// Skipped pages are recorded without an output so a page that has
+			// just become a draft or expired has its previous output removed.
+			current.Pages[page.relPath] = manifestEntry{Hash: page.hash}
+			continue
 		}
-		ext := filepath.Ext(info.Name())
-		if ext != ".html" && ext != ".md" {
-			return nil
+		published = append(published, page)
+		if page.Draft {
+			drafts++
 		}
+	}
+	if drafts > 0 {
+		fmt.Printf("Including %d draft pages.\n", drafts)
+	}
 
-		contentBytes, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", path, err)
+	if err := resolveOutputs(published, site); err != nil {
+		return 0, err
+	}
+	linkTranslations(published, languages)
+	linkTargets := make(map[string]string, len(published))
+	for _, page := range published {
+		linkTargets[filepath.ToSlash(page.relPath)] = page.URL
+	}
+
+	markdown := newMarkdownRenderer(site)
+	sanitizer := newSanitizer(site.Sanitize, opts.Unsafe)
+	images := newImageProcessor(staticDir, outputDir, opts.CacheDir, site.Images)
+
+	// Each language is built on its own: its pages only list each other and
+	// it gets its own sections, taxonomies and feeds below its directory.
+	passes := make([]languagePass, len(languages))
+	for i, lang := range languages {
+		langPages := pagesIn(published, lang)
+		siteData := SiteData{
+			SiteConfig: localize(site, lang),
+			Pages:      Pages(langPages),
+			Language:   lang,
+			markdown:   markdown,
+			sanitizer:  sanitizer,
 		}
-		if !utf8.Valid(contentBytes) {
-			return fmt.Errorf("content file is not valid UTF-8: %s", path)
+		if len(languages) > 1 {
+			siteData.Languages = languages
 		}
+		siteData.HasFeed = !site.Feeds.Disabled && len(feedPages(siteData.Pages)) > 0
+		siteData.HighlightCSS = highlightStylesheet(site.Highlight)
+		siteData.Taxonomies = buildTaxonomies(site.Taxonomies, siteData.Pages, site.PrettyURLs, lang.Dir)
+		sections := buildSections(langPages, site.PrettyURLs, languageRoot(lang))
+		linkSiblings(sections)
+		passes[i] = languagePass{site: siteData, sections: sections}
 
-		meta, htmlOut, parseErr := processContent(contentBytes, opts)
-		if parseErr != nil {
-			return fmt.Errorf("failed to process content for %s: %w", path, parseErr)
+		// Directories without a hand-written index get a generated list page,
+		// provided the theme ships a list layout for them.
+		for _, sec := range sortedSections(sections) {
+			if sec.index == nil {
+				if list := sec.generatedList(siteData); theme.has(list.layout) {
+					passes[i].generated = append(passes[i].generated, list)
+				}
+			}
 		}
+		passes[i].generated = append(passes[i].generated, taxonomyPages(siteData, theme)...)
+	}
 
-		relPath, err := filepath.Rel(contentDir, path)
-		if err != nil {
-			return err
+	// Every file written for a page is claimed up front, so a generated
+	// page, the 404 page or an alias never silently replaces another.
+	var generated []generatedPage
+	for _, pass := range passes {
+		generated = append(generated, pass.generated...)
+	}
+	taken, err := claimOutputs(published, generated)
+	if err != nil {
+		return 0, err
+	}
+	// A hand-written content page at 404.html takes precedence.
+	defaultSite := passes[0].site
+	notFound := notFoundPage(defaultSite)
+	writeNotFound := theme.has(notFound.layout) && taken[notFound.url] == ""
+	if writeNotFound {
+		taken[notFound.url] = "the 404 page"
+	}
+	aliases, err := resolveAliases(published, site.PrettyURLs, taken)
+	if err != nil {
+		return 0, err
+	}
+
+	// Content is rendered first, as listings show the summary and word count
+	// of other pages. Unchanged pages keep those from the previous build.
+	for _, pass := range passes {
+		var pending []*Page
+		for _, page := range pass.site.Pages {
+			if incremental {
+				if entry, ok := previous.Pages[page.relPath]; ok && entry.restoreContent(page) {
+					continue
+				}
+			}
+			pending = append(pending, page)
+		}
+		theme.prepare(pass.site, assets, images)
+		if err := renderContents(pending, linkTargets, pass.site, theme); err != nil {
+			return 0, err
 		}
+	}
+
+	current.Collection = collectionHash(published)
+	// Every template can list other pages through .Site.Pages, so a page can
+	// only be reused when no page has been added, removed, retitled or moved,
+	// and no summary or word count has changed.
+	reuse := incremental && current.Collection != "" && previous.Collection == current.Collection
 
-		if meta.Draft && !isExceptionPage(strings.TrimSuffix(relPath, ext)) {
-			return nil
+	// Pages that are not reused need their HTML, which pages restored from
+	// the manifest do not have yet. It is rendered before any layout runs,
+	// as layouts read the content fields of other pages.
+	statuses := make(map[*Page]pageStatus, len(published))
+	for _, pass := range passes {
+		var pending []*Page
+		for _, page := range pass.site.Pages {
+			statuses[page] = pageRendered
+			if reuse {
+				if entry, ok := previous.Pages[page.relPath]; ok && page.reusable(entry, outputDir) {
+					statuses[page] = pageReused
+					continue
+				}
+			}
+			if !page.rendered {
+				pending = append(pending, page)
+			}
+		}
+		theme.prepare(pass.site, assets, images)
+		if err := renderContents(pending, linkTargets, pass.site, theme); err != nil {
+			return 0, err
 		}
+	}
 
-		outputPath := filepath.Join(outputDir, strings.TrimSuffix(relPath, ext)+".html")
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-			return err
+	pagesGenerated, pagesReused := 0, 0
+	for _, pass := range passes {
+		siteData, sections := pass.site, pass.sections
+		langPages := siteData.Pages
+		theme.prepare(siteData, assets, images)
+
+		if err := forEachParallel(len(langPages), func(i int) error {
+			page := langPages[i]
+			if statuses[page] == pageReused {
+				return nil
+			}
+			var children Pages
+			if page.isIndex() {
+				children = sections[page.Section].pages
+			}
+			return buildPage(page, children, outputDir, siteData, theme)
+		}); err != nil {
+			return 0, err
 		}
 
-		pageData := PageData{
-			Content:     template.HTML(htmlOut),
-			Title:       meta.Title,
-			BaseHref:    util.ComputeBaseHref(relPath),
-			Description: meta.Description,
-			Site:        site,
-			ShowEditML:  meta.ShowEditML,
-			StoryTitle:  meta.StoryTitle,
-			Params:      meta.Params, // Pass arbitrary params to the template
+		for _, page := range langPages {
+			current.Pages[page.relPath] = newManifestEntry(page)
+			pagesGenerated++
+			if statuses[page] == pageReused {
+				pagesReused++
+			}
 		}
 
-		if meta.StoryAuthor != "" {
-			pageData.Author = meta.StoryAuthor
-		} else {
-			pageData.Author = site.Author
+		if err := forEachParallel(len(pass.generated), func(i int) error {
+			return pass.generated[i].render(outputDir, theme)
+		}); err != nil {
+			return 0, err
 		}
-		if pageData.Description == "" {
-			pageData.Description = site.Description
+
+		feeds, err := writeFeeds(outputDir, siteData, sections)
+		if err != nil {
+			return 0, fmt.Errorf("failed to write feeds: %w", err)
 		}
+		current.Generated = append(current.Generated, feeds...)
+	}
+	if pagesReused > 0 {
+		fmt.Printf("Reused %d unchanged pages.\n", pagesReused)
+	}
+	for _, gen := range generated {
+		current.Generated = append(current.Generated, gen.url)
+	}
+	pagesGenerated += len(generated)
 
-		if err := renderPage(tmpl, outputPath, pageData); err != nil {
-			return fmt.Errorf("failed to render page %s: %w", path, err)
+	if writeNotFound {
+		theme.prepare(defaultSite, assets, images)
+		if err := notFound.render(outputDir, theme); err != nil {
+			return 0, err
 		}
-		pagesGenerated++
-		return nil
-	}); err != nil {
+		current.Generated = append(current.Generated, notFound.url)
+	}
+	written, err := writeAliases(outputDir, aliases, defaultSite)
+	if err != nil {
 		return 0, err
 	}
+	current.Generated = append(current.Generated, written...)
 
-	if err := copyStaticAssets(staticDir, outputDir); err != nil {
+	sitemap, err := writeSitemap(outputDir, staticDir, SiteData{SiteConfig: site, Pages: Pages(published)}, generated)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write sitemap: %w", err)
+	}
+	current.Generated = append(current.Generated, sitemap...)
+
+	stylesheet, err := writeHighlightCSS(outputDir, site.Highlight)
+	if err != nil {
 		return 0, err
 	}
-	return pagesGenerated, nil
-}
+	if stylesheet != "" {
+		current.Generated = append(current.Generated, stylesheet)
+	}
 
-// copyStaticAssets copies files from the static directory to the output directory.
-func copyStaticAssets(staticDir, outputDir string) error {
-	// This map defines the file extensions that are considered "static assets".
-	// You can add or remove extensions here as needed (e.g., ".woff", ".woff2").
-	allowedExts := map[string]bool{
-		".css": true, ".js": true, ".txt": true, ".svg": true,
-		".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	// Static files are copied last so they can override generated files.
+	static, err := assets.write(outputDir)
+	if err != nil {
+		return 0, err
 	}
-	return filepath.Walk(staticDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
+	copied, err := copyStaticAssets(staticDir, outputDir, site.Static, assets.handled())
+	if err != nil {
+		return 0, err
+	}
+	current.Static = append(static, copied...)
+
+	// Reused pages still link the variants they were rendered with.
+	current.Images, current.ImageFiles = images.outputs(), images.files()
+	if reuse {
+		for _, output := range previous.Images {
+			if !containsString(current.Images, output) {
+				current.Images = append(current.Images, output)
+			}
 		}
-		// Skip files with extensions that are not in our allowed list.
-		if !allowedExts[filepath.Ext(info.Name())] {
-			return nil
+		for rel, h := range previous.ImageFiles {
+			if _, ok := current.ImageFiles[rel]; !ok {
+				current.ImageFiles[rel] = h
+			}
 		}
+	}
 
-		rel, err := filepath.Rel(staticDir, path)
-		if err != nil {
-			return err
+	if previous != nil {
+		if err := removeStaleOutputs(outputDir, previous, current); err != nil {
+			return 0, err
 		}
-		dest := filepath.Join(outputDir, rel)
-		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
-			return err
+	}
+	if err := current.save(outputDir); err != nil {
+		return 0, fmt.Errorf("failed to write build manifest: %w", err)
+	}
+	return pagesGenerated, nil
+}
+
+// removeStaleOutputs deletes files produced by the previous build that the
+// current build no longer produces, e.g. pages whose sources were removed.
+func removeStaleOutputs(outputDir string, previous, current *manifest) error {
+	live := current.outputs()
+	for output := range previous.outputs() {
+		if live[output] {
+			continue
 		}
-		src, err := os.Open(path)
-		if err != nil {
-			return err
+		err := os.Remove(filepath.Join(outputDir, filepath.FromSlash(output)))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale page %s: %w", output, err)
 		}
-		defer src.Close()
-		dst, err := os.Create(dest)
+	}
+	return nil
+}
+
+// pageStatus describes what happened to a page during the build.
+type pageStatus int
+
+const (
+	pageRendered pageStatus = iota // Rendered and written
+	pageReused                     // Unchanged since the last build
+)
+
+// forEachParallel calls fn for every index in [0, n) on a worker pool bounded
+// by the CPU count. It waits for all calls and returns the error with the
+// lowest index, so failures are reported in a stable order.
+func forEachParallel(n int, fn func(i int) error) error {
+	workers := runtime.NumCPU()
+	if n < workers {
+		workers = n
+	}
+
+	errs := make([]error, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
 		if err != nil {
 			return err
 		}
-		defer dst.Close()
-		_, err = io.Copy(dst, src)
-		return err
-	})
+	}
+	return nil
+}
+
+// languagePass is the part of a build that covers one language.
+type languagePass struct {
+	site      SiteData
+	sections  map[string]*section
+	generated []generatedPage // List and taxonomy pages
 }
 
-// isExceptionPage checks for pages that should not be considered drafts.
-func isExceptionPage(slug string) bool {
-	// This can be expanded with other "special" pages like "404" or "sitemap".
-	return slug == "index" || slug == "about" || slug == "menu"
+// pageContent is what renderContent works out for a page.
+type pageContent struct {
+	html          template.HTML
+	headings      []*Heading
+	summary       template.HTML
+	truncated     bool
+	words         int
+	manualSummary bool
+}
+
+// renderContents renders the content of pages of one language in parallel.
+// The results are only stored once every page is done, since shortcodes
+// may read other pages while rendering runs.
+func renderContents(pages []*Page, linkTargets map[string]string, site SiteData, theme *Theme) error {
+	results := make([]*pageContent, len(pages))
+	if err := forEachParallel(len(pages), func(i int) error {
+		content, err := renderContent(pages[i], linkTargets, site, theme)
+		results[i] = content
+		return err
+	}); err != nil {
+		return err
+	}
+	for i, page := range pages {
+		page.setContent(results[i])
+	}
+	return nil
 }
 
-// renderPage executes the Go template and writes the output to a file.
-func renderPage(tmpl *template.Template, outPath string, data PageData) error {
-	outFile, err := os.Create(outPath)
+// renderContent expands the shortcodes of a page and renders its markdown,
+// then works out its summary and word count from the result. It only reads
+// the page; setContent stores the result.
+func renderContent(page *Page, linkTargets map[string]string, site SiteData, theme *Theme) (*pageContent, error) {
+	pageData := newPageData(page, site)
+	links := &linkResolver{
+		sourceDir: path.Dir(filepath.ToSlash(page.relPath)),
+		baseHref:  pageData.BaseHref,
+		targets:   linkTargets,
+	}
+	body, shortcodes, err := theme.expandShortcodes(page.body, pageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand shortcodes in %s: %w", page.sourcePath, err)
+	}
+	policy, err := site.sanitizer.policy(page.Trust)
 	if err != nil {
+		return nil, fmt.Errorf("%s: %w", page.sourcePath, err)
+	}
+	rendered, err := processContent(site.markdown, body, policy, links)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process content for %s: %w", page.sourcePath, err)
+	}
+	if len(rendered.stripped) > 0 {
+		fmt.Printf("Warning: %s: removed unsafe HTML: %s\n", page.sourcePath, strings.Join(rendered.stripped, ", "))
+	}
+
+	content := &pageContent{html: shortcodes.restore(rendered.html), headings: rendered.headings}
+	content.words = countWords(string(content.html))
+	if rendered.summary != "" {
+		content.summary = shortcodes.restore(rendered.summary)
+		content.truncated = rendered.more
+		content.manualSummary = true
+	} else {
+		content.summary, content.truncated = autoSummary(string(content.html), site.SummaryLength)
+	}
+	return content, nil
+}
+
+// setContent stores the rendered content of the page.
+func (p *Page) setContent(content *pageContent) {
+	p.content = content.html
+	p.headings = content.headings
+	p.WordCount = content.words
+	p.ReadingTime = readingTime(content.words)
+	p.Summary = content.summary
+	p.Truncated = content.truncated
+	p.manualSummary = content.manualSummary
+	p.rendered = true
+}
+
+// reusable reports whether the page's output from the previous build can
+// be kept as it is.
+func (p *Page) reusable(prev manifestEntry, outputDir string) bool {
+	if prev.Hash != p.hash || prev.Output != p.outputPath {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(p.outputPath)))
+	return err == nil
+}
+
+// buildPage renders a content page through its layout and writes it. The
+// page's content must have been rendered already.
+func buildPage(page *Page, children Pages, outputDir string, site SiteData, theme *Theme) error {
+	outputPath := filepath.Join(outputDir, filepath.FromSlash(page.outputPath))
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
 		return err
 	}
-	defer outFile.Close()
-	// "main" is the name of the template defined within our layout file.
-	return tmpl.ExecuteTemplate(outFile, "main", data)
-}
-
-// LoadTemplates parses all necessary template files from a given theme directory.
-func LoadTemplates(templateDir, templateName string) (*template.Template, error) {
-	path := filepath.Join(templateDir, templateName)
-	// This function assumes a specific structure for templates:
-	// a layout file and partials for header/footer.
-	tmpl, err := template.ParseFiles(
-		filepath.Join(path, "layout.html"),
-		filepath.Join(path, "header.html"),
-		filepath.Join(path, "footer.html"),
-	)
+
+	pageData := newPageData(page, site)
+	pageData.Content = page.content
+	pageData.Headings = headingTree(page.headings, page.TocDepth)
+	pageData.TableOfContents = renderTOC(pageData.Headings)
+	pageData.Pages = children
+
+	layout := layoutQuery{kind: "single", layout: page.Layout, typ: page.Type, section: page.Section}
+	if err := theme.render(layout, outputPath, pageData); err != nil {
+		return fmt.Errorf("failed to render page %s: %w", page.sourcePath, err)
+	}
+	return nil
+}
+
+// generatedPage is an output without a source file of its own, such as a
+// section list page or a taxonomy term page.
+type generatedPage struct {
+	url    string // Slash-separated output path relative to the output dir
+	layout layoutQuery
+	data   PageData
+}
+
+// render executes the page's layout into the output directory.
+func (g generatedPage) render(outputDir string, theme *Theme) error {
+	outputPath := filepath.Join(outputDir, filepath.FromSlash(g.url))
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return err
+	}
+	if err := theme.render(g.layout, outputPath, g.data); err != nil {
+		return fmt.Errorf("failed to render generated page %s: %w", g.url, err)
+	}
+	return nil
+}
+
+// generatedList builds the list page of a section without a hand-written index.
+func (s *section) generatedList(site SiteData) generatedPage {
+	pageData := newPageData(s.listPage(site.PrettyURLs), site)
+	pageData.Pages = s.pages
+	// Scene directories hold knots of a single story; carry its title and
+	// author over so the list page has the same header as its children.
+	for _, child := range s.pages {
+		if child.StoryTitle != "" {
+			pageData.StoryTitle = child.StoryTitle
+			if child.StoryAuthor != "" {
+				pageData.Author = child.StoryAuthor
+			}
+			break
+		}
+	}
+	// The root of a language lists the site under its localized title.
+	if s.path == languageRoot(site.Language) {
+		pageData.Title = site.Title
+	}
+	return generatedPage{url: s.listOutput(), layout: layoutQuery{kind: "list", section: s.path}, data: pageData}
+}
+
+// newPageData fills in the template data shared by every kind of page.
+func newPageData(page *Page, site SiteData) PageData {
+	pageData := PageData{
+		Title:        page.Title,
+		BaseHref:     util.ComputeBaseHref(filepath.FromSlash(page.outputPath)),
+		Description:  page.Description,
+		Site:         site,
+		ShowEditML:   page.ShowEditML,
+		StoryTitle:   page.StoryTitle,
+		Params:       page.Params, // Pass arbitrary params to the template
+		Date:         page.Date,
+		PublishDate:  page.PublishDate,
+		ExpiryDate:   page.ExpiryDate,
+		Lastmod:      page.Lastmod,
+		Draft:        page.Draft,
+		Summary:      page.Summary,
+		Truncated:    page.Truncated,
+		WordCount:    page.WordCount,
+		ReadingTime:  page.ReadingTime,
+		Translations: page.translations,
+		Prev:         page.prev,
+		Next:         page.next,
+	}
+
+	if page.StoryAuthor != "" {
+		pageData.Author = page.StoryAuthor
+	} else {
+		pageData.Author = site.Author
+	}
+	if pageData.Description == "" {
+		pageData.Description = site.Description
+	}
+	return pageData
+}
+
+// notFoundPage builds the 404.html page from the theme's 404 layout. It is
+// served for any missing path, so it links the site root-relative from
+// baseurl instead of relative to its own location.
+func notFoundPage(site SiteData) generatedPage {
+	pageData := newPageData(&Page{PageMeta: PageMeta{Title: "Page Not Found"}, outputPath: "404.html"}, site)
+	pageData.BaseHref = sitePath(site.BaseURL)
+	return generatedPage{url: "404.html", layout: layoutQuery{kind: "404"}, data: pageData}
+}
+
+// sitePath returns the path of the site root on its server, e.g. "/stories/"
+// for a baseurl of "https://example.com/stories/".
+func sitePath(baseURL string) string {
+	u, err := url.Parse(baseURL)
 	if err != nil {
-		return nil, err
+		return "/"
 	}
-	return tmpl, nil
+	if p := strings.Trim(u.Path, "/"); p != "" {
+		return "/" + p + "/"
+	}
+	return "/"
 }
 
+// defaultAlwaysPublish are the pages published despite `draft: true` when
+// site.yaml has no always_publish list.
+var defaultAlwaysPublish = []string{"index", "about", "menu"}
+
+// alwaysPublished reports whether a page matches one of the always_publish
+// patterns, which name content paths with or without their extension. On
+// multilingual sites, "about" covers the about page of every language.
+func alwaysPublished(page *Page, patterns []string) bool {
+	if patterns == nil {
+		patterns = defaultAlwaysPublish
+	}
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "/")
+		pattern = strings.TrimSuffix(pattern, path.Ext(pattern))
+		for _, slug := range []string{page.slug(), page.langSlug()} {
+			if ok, _ := path.Match(pattern, slug); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
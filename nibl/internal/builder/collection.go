@@ -0,0 +1,140 @@
+// internal/builder/collection.go
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Pages is an ordered collection of pages as exposed to templates, e.g.
+// {{ range .Site.Pages.InSection "chapters" }} or {{ range .Pages.ByWeight }}.
+// Every method returns a new collection and leaves the receiver untouched,
+// since the same collection is shared by pages rendered in parallel.
+type Pages []*Page
+
+// ByTitle returns the pages sorted alphabetically by title.
+func (ps Pages) ByTitle() Pages {
+	return ps.sorted(func(a, b *Page) bool {
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	})
+}
+
+// ByDate returns the pages sorted by date, oldest first.
+// Pages without a date sort before dated ones.
+func (ps Pages) ByDate() Pages {
+	return ps.sorted(func(a, b *Page) bool {
+		return a.Date.Before(b.Date.Time)
+	})
+}
+
+// ByLastmod returns the pages sorted by their last modification, oldest first.
+func (ps Pages) ByLastmod() Pages {
+	return ps.sorted(func(a, b *Page) bool {
+		return a.Lastmod.Before(b.Lastmod.Time)
+	})
+}
+
+// ByWeight returns the pages sorted by ascending weight. Pages without a
+// weight come last, and ties are broken by title.
+func (ps Pages) ByWeight() Pages {
+	return ps.sorted(func(a, b *Page) bool {
+		if a.Weight != b.Weight {
+			if a.Weight == 0 || b.Weight == 0 {
+				return b.Weight == 0
+			}
+			return a.Weight < b.Weight
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	})
+}
+
+// ByOrder returns the pages in reading order, as used by .Prev and .Next:
+// by weight, then by date, then by file name. Pages with a weight or date
+// come before pages without one.
+func (ps Pages) ByOrder() Pages {
+	return ps.sorted(func(a, b *Page) bool {
+		if a.Weight != b.Weight {
+			if a.Weight == 0 || b.Weight == 0 {
+				return b.Weight == 0
+			}
+			return a.Weight < b.Weight
+		}
+		if !a.Date.Equal(b.Date.Time) {
+			if a.Date.IsZero() || b.Date.IsZero() {
+				return b.Date.IsZero()
+			}
+			return a.Date.Before(b.Date.Time)
+		}
+		return a.slug() < b.slug()
+	})
+}
+
+// Reverse returns the pages in reverse order.
+func (ps Pages) Reverse() Pages {
+	reversed := make(Pages, len(ps))
+	for i, page := range ps {
+		reversed[len(ps)-1-i] = page
+	}
+	return reversed
+}
+
+// Limit returns at most the first n pages.
+func (ps Pages) Limit(n int) Pages {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(ps) {
+		n = len(ps)
+	}
+	return append(Pages(nil), ps[:n]...)
+}
+
+// InSection returns the pages that live directly in the given content
+// directory, e.g. "chapters" or "story/forest". Use "" for the root.
+func (ps Pages) InSection(section string) Pages {
+	section = strings.Trim(section, "/")
+	return ps.filter(func(p *Page) bool { return p.Section == section })
+}
+
+// Where returns the pages whose front matter param key equals value.
+// For list params such as tags, a page matches when the list contains value.
+func (ps Pages) Where(key string, value interface{}) Pages {
+	want := fmt.Sprint(value)
+	return ps.filter(func(p *Page) bool {
+		got, ok := p.Params[key]
+		if !ok {
+			return false
+		}
+		if list, ok := got.([]interface{}); ok {
+			for _, item := range list {
+				if fmt.Sprint(item) == want {
+					return true
+				}
+			}
+			return false
+		}
+		return fmt.Sprint(got) == want
+	})
+}
+
+// Len returns the number of pages, for use in templates.
+func (ps Pages) Len() int {
+	return len(ps)
+}
+
+func (ps Pages) sorted(less func(a, b *Page) bool) Pages {
+	sorted := append(Pages(nil), ps...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	return sorted
+}
+
+func (ps Pages) filter(keep func(p *Page) bool) Pages {
+	var filtered Pages
+	for _, page := range ps {
+		if keep(page) {
+			filtered = append(filtered, page)
+		}
+	}
+	return filtered
+}
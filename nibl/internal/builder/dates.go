@@ -0,0 +1,95 @@
+// internal/builder/dates.go
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dateLayouts are the formats accepted for front matter dates, tried in order.
+// Dates without a zone are taken as UTC.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+}
+
+// Date is a front matter date such as `date: 2024-03-01` or
+// `publishDate: "March 1, 2024"`. It embeds time.Time, so templates can call
+// {{ .Date.Format "2 January 2006" }} directly; it is zero when absent.
+type Date struct {
+	time.Time
+}
+
+// parseDate parses s against dateLayouts.
+func parseDate(s string) (Date, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Date{}, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return Date{t}, nil
+		}
+	}
+	return Date{}, fmt.Errorf("unrecognised date %q (try YYYY-MM-DD or RFC 3339)", s)
+}
+
+// UnmarshalYAML accepts both YAML timestamps and quoted strings.
+func (d *Date) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := parseDate(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = parsed
+	return nil
+}
+
+// UnmarshalText lets other decoders parse dates from strings.
+func (d *Date) UnmarshalText(text []byte) error {
+	parsed, err := parseDate(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// applyDateDefaults fills in the dates a page did not set: date falls back
+// to publishDate, and lastmod to date.
+func (m *PageMeta) applyDateDefaults() {
+	if m.Date.IsZero() {
+		m.Date = m.PublishDate
+	}
+	if m.Lastmod.IsZero() {
+		m.Lastmod = m.Date
+	}
+}
+
+// isScheduled reports whether the page's publish date is still in the future.
+func (m *PageMeta) isScheduled(now time.Time) bool {
+	publish := m.PublishDate
+	if publish.IsZero() {
+		publish = m.Date
+	}
+	return publish.After(now)
+}
+
+// isExpired reports whether the page's expiry date has passed.
+func (m *PageMeta) isExpired(now time.Time) bool {
+	return !m.ExpiryDate.IsZero() && !m.ExpiryDate.After(now)
+}
@@ -0,0 +1,100 @@
+// internal/builder/dates_test.go
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "", want: time.Time{}},
+		{in: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "  2024/03/01 ", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "2024-03-01T09:30:00", want: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)},
+		{in: "2024-03-01T09:30:00+02:00", want: time.Date(2024, 3, 1, 7, 30, 0, 0, time.UTC)},
+		{in: "2024-03-01T09:30:00.5Z", want: time.Date(2024, 3, 1, 9, 30, 0, 500000000, time.UTC)},
+		{in: "2024-03-01 09:30:00 -0500", want: time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)},
+		{in: "2024-03-01 09:30", want: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)},
+		{in: "Fri, 01 Mar 2024 09:30:00 +0000", want: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)},
+		{in: "Mar 1, 2024", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "March 1, 2024", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "1 March 2024", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "01/03/2024", wantErr: true},
+		{in: "2024-13-01", wantErr: true},
+		{in: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseDate(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDate(%q): %v", tt.in, err)
+		} else if !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %v, want %v", tt.in, got.Time, tt.want)
+		}
+	}
+}
+
+func TestDateFrontMatter(t *testing.T) {
+	var meta PageMeta
+	src := "date: 2024-03-01\npublishDate: \"March 2, 2024\"\n"
+	if err := yaml.Unmarshal([]byte(src), &meta); err != nil {
+		t.Fatal(err)
+	}
+	meta.applyDateDefaults()
+	if meta.Date.Format("2006-01-02") != "2024-03-01" || meta.Lastmod != meta.Date {
+		t.Errorf("date %v, lastmod %v", meta.Date, meta.Lastmod)
+	}
+
+	meta = PageMeta{}
+	if err := yaml.Unmarshal([]byte("title: x\npublishDate: 2024-03-02\n"), &meta); err != nil {
+		t.Fatal(err)
+	}
+	meta.applyDateDefaults()
+	if meta.Date.Format("2006-01-02") != "2024-03-02" {
+		t.Errorf("date should fall back to publishDate, got %v", meta.Date)
+	}
+
+	err := yaml.Unmarshal([]byte("title: x\ndate: soon\n"), &PageMeta{})
+	if err == nil || err.Error() != `line 2: unrecognised date "soon" (try YYYY-MM-DD or RFC 3339)` {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestScheduledAndExpired(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := func(d int) Date { return Date{time.Date(2024, 6, d, 0, 0, 0, 0, time.UTC)} }
+	tests := []struct {
+		name      string
+		meta      PageMeta
+		scheduled bool
+		expired   bool
+	}{
+		{name: "undated", meta: PageMeta{}},
+		{name: "past date", meta: PageMeta{Date: day(1)}},
+		{name: "future date", meta: PageMeta{Date: day(2)}, scheduled: true},
+		{name: "publishDate wins", meta: PageMeta{Date: day(2), PublishDate: day(1)}},
+		{name: "future publishDate", meta: PageMeta{PublishDate: day(3)}, scheduled: true},
+		{name: "expired", meta: PageMeta{ExpiryDate: day(1)}, expired: true},
+		{name: "not yet expired", meta: PageMeta{ExpiryDate: day(2)}},
+	}
+	for _, tt := range tests {
+		if got := tt.meta.isScheduled(now); got != tt.scheduled {
+			t.Errorf("%s: isScheduled = %v", tt.name, got)
+		}
+		if got := tt.meta.isExpired(now); got != tt.expired {
+			t.Errorf("%s: isExpired = %v", tt.name, got)
+		}
+	}
+}
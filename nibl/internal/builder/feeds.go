@@ -0,0 +1,253 @@
+// internal/builder/feeds.go
+package builder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"nibl/internal/util"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	rssFeedFile  = "index.xml"
+	atomFeedFile = "atom.xml"
+)
+
+// feed is the format-neutral description of one feed before it is encoded.
+type feed struct {
+	dir     string // Slash-separated output dir, "" for the site feed
+	title   string
+	link    string // Absolute URL of the page the feed belongs to
+	entries Pages  // Newest first
+}
+
+// feedPages returns the pages that go into feeds, newest first. Drafts
+// built with --drafts are left out, so previews never reach subscribers.
+func feedPages(pages Pages) Pages {
+	var dated Pages
+	for _, page := range pages {
+		if !page.Date.IsZero() && !page.Draft {
+			dated = append(dated, page)
+		}
+	}
+	return dated.ByDate().Reverse()
+}
+
+// writeFeeds writes an RSS 2.0 and an Atom feed for the whole site, or for
+// the root of the language being built, and for every section that contains
+// dated pages. Only pages with a front matter date are included, newest
+// first. It returns the outputs it wrote.
+func writeFeeds(outputDir string, site SiteData, sections map[string]*section) ([]string, error) {
+	if site.Feeds.Disabled {
+		return nil, nil
+	}
+
+	dated := feedPages(site.Pages)
+	if len(dated) == 0 {
+		return nil, nil
+	}
+
+	if !util.IsAbsoluteURL(site.BaseURL) {
+		fmt.Printf("Warning: baseurl %q is not an absolute URL; feed links will not work in feed readers.\n", site.BaseURL)
+	}
+
+	root := languageRoot(site.Language)
+	feeds := []feed{{dir: root, title: site.Title, link: util.AbsURL(site.BaseURL, site.Language.Dir), entries: dated}}
+	for _, sec := range sortedSections(sections) {
+		if sec.path == root {
+			continue
+		}
+		var entries Pages
+		for _, page := range dated {
+			if page.Section == sec.path || strings.HasPrefix(page.Section, sec.path+"/") {
+				entries = append(entries, page)
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		feeds = append(feeds, feed{
+			dir:     sec.path,
+			title:   site.Title + " - " + sec.listPage(site.PrettyURLs).Title,
+			link:    util.AbsURL(site.BaseURL, sec.path+"/"),
+			entries: entries,
+		})
+	}
+
+	var written []string
+	for _, f := range feeds {
+		if site.Feeds.Limit > 0 {
+			f.entries = f.entries.Limit(site.Feeds.Limit)
+		}
+		rssURL := path.Join(f.dir, rssFeedFile)
+		atomURL := path.Join(f.dir, atomFeedFile)
+		if err := writeXML(outputDir, rssURL, f.rss(site, util.AbsURL(site.BaseURL, rssURL))); err != nil {
+			return nil, err
+		}
+		if err := writeXML(outputDir, atomURL, f.atom(site, util.AbsURL(site.BaseURL, atomURL))); err != nil {
+			return nil, err
+		}
+		written = append(written, rssURL, atomURL)
+	}
+	return written, nil
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomDoc struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Summary   string      `xml:"summary,omitempty"`
+}
+
+// rss encodes the feed as RSS 2.0.
+func (f feed) rss(site SiteData, self string) rssDoc {
+	channel := rssChannel{
+		Title:         f.title,
+		Link:          f.link,
+		Description:   site.Description,
+		LastBuildDate: f.updated().Format(time.RFC1123Z),
+		Self:          atomLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+	}
+	for _, page := range f.entries {
+		link := util.AbsURL(site.BaseURL, page.URL)
+		channel.Items = append(channel.Items, rssItem{
+			Title:       page.Title,
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: true, Value: link},
+			PubDate:     page.Date.Format(time.RFC1123Z),
+			Description: feedSummary(page),
+		})
+	}
+	return rssDoc{Version: "2.0", AtomNS: "http://www.w3.org/2005/Atom", Channel: channel}
+}
+
+// updated returns the latest lastmod of the feed's entries, which need not
+// be the newest entry's: an older page may have been edited since.
+func (f feed) updated() time.Time {
+	var latest time.Time
+	for _, page := range f.entries {
+		if page.Lastmod.After(latest) {
+			latest = page.Lastmod.Time
+		}
+	}
+	return latest
+}
+
+// atom encodes the feed as Atom 1.0.
+func (f feed) atom(site SiteData, self string) atomDoc {
+	doc := atomDoc{
+		Title:   f.title,
+		ID:      f.link,
+		Updated: f.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.link, Rel: "alternate", Type: "text/html"},
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	if site.Author != "" {
+		doc.Author = &atomAuthor{Name: site.Author}
+	}
+	for _, page := range f.entries {
+		link := util.AbsURL(site.BaseURL, page.URL)
+		entry := atomEntry{
+			Title:     page.Title,
+			ID:        link,
+			Link:      atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Published: page.Date.Format(time.RFC3339),
+			Updated:   page.Lastmod.Format(time.RFC3339),
+			Summary:   feedSummary(page),
+		}
+		if author := pageAuthor(page); author != "" && author != site.Author {
+			entry.Author = &atomAuthor{Name: author}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return doc
+}
+
+// feedSummary describes a page in a feed: its description, or else its
+// summary as plain text, since relative links do not work in feed readers.
+func feedSummary(page *Page) string {
+	if page.Description != "" {
+		return page.Description
+	}
+	return strings.TrimSpace(plainify(string(page.Summary)))
+}
+
+// pageAuthor returns the author a page credits, preferring the story author.
+func pageAuthor(page *Page) string {
+	if page.StoryAuthor != "" {
+		return page.StoryAuthor
+	}
+	return page.Author
+}
+
+// writeXML encodes v with an XML header into the output directory.
+func writeXML(outputDir, url string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", url, err)
+	}
+	outputPath := filepath.Join(outputDir, filepath.FromSlash(url))
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return os.WriteFile(outputPath, append(data, '\n'), 0644)
+}
@@ -0,0 +1,26 @@
+// internal/builder/feeds_test.go
+package builder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFeedPages(t *testing.T) {
+	day := func(d int) Date { return Date{time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC)} }
+	pages := Pages{
+		{PageMeta: PageMeta{Title: "old", Date: day(1), Lastmod: day(20)}},
+		{PageMeta: PageMeta{Title: "undated"}},
+		{PageMeta: PageMeta{Title: "new", Date: day(10), Lastmod: day(10)}},
+		{PageMeta: PageMeta{Title: "draft", Date: day(15), Lastmod: day(25), Draft: true}},
+	}
+	entries := feedPages(pages)
+	if len(entries) != 2 || entries[0].Title != "new" || entries[1].Title != "old" {
+		t.Fatalf("got %d entries, want new and old", len(entries))
+	}
+
+	doc := feed{title: "Site", link: "https://example.com/", entries: entries}.atom(SiteData{}, "https://example.com/atom.xml")
+	if want := "2024-05-20T00:00:00Z"; doc.Updated != want {
+		t.Errorf("feed updated %s, want the latest lastmod %s", doc.Updated, want)
+	}
+}
@@ -0,0 +1,178 @@
+// internal/builder/frontmatter.go
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Front matter is only recognised at the very start of a file:
+//
+//	---      YAML, closed by another ---
+//	+++      TOML, closed by another +++
+//	{"       a JSON object, e.g. {"title": "Mara"}
+//
+// Anything else, including a later `---` horizontal rule, is page content.
+var frontMatterDelimiters = map[string]string{
+	"---": "yaml",
+	"+++": "toml",
+}
+
+// splitFrontMatter separates the front matter from the markdown body.
+// It is cheap enough to run for every page, including ones that are reused.
+// Errors name the file and the line within it, e.g. "content/a.md:4: ...".
+func splitFrontMatter(sourcePath string, raw []byte) (PageMeta, []byte, error) {
+	content := bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))
+	fail := func(line int, err error) (PageMeta, []byte, error) {
+		if line > 0 {
+			return PageMeta{}, nil, fmt.Errorf("%s:%d: %w", sourcePath, line, err)
+		}
+		return PageMeta{}, nil, fmt.Errorf("%s: %w", sourcePath, err)
+	}
+
+	if isJSONFrontMatter(content) {
+		dec := json.NewDecoder(bytes.NewReader(content))
+		var values map[string]interface{}
+		if err := dec.Decode(&values); err != nil {
+			return fail(jsonErrorLine(content, err), fmt.Errorf("invalid JSON front matter: %w", err))
+		}
+		meta, err := decodeFrontMatterValues(values)
+		if err != nil {
+			return fail(0, err)
+		}
+		return meta, content[dec.InputOffset():], nil
+	}
+
+	first, rest := cutLine(content)
+	delimiter := bytes.TrimRight(first, " \t\r\n")
+	format, ok := frontMatterDelimiters[string(delimiter)]
+	if !ok {
+		return PageMeta{}, raw, nil
+	}
+
+	// Find the closing delimiter on a line of its own.
+	var block []byte
+	body := rest
+	closed := false
+	for len(body) > 0 {
+		line, next := cutLine(body)
+		if bytes.Equal(bytes.TrimRight(line, " \t\r\n"), delimiter) {
+			block = rest[:len(rest)-len(body)]
+			body = next
+			closed = true
+			break
+		}
+		body = next
+	}
+	if !closed {
+		return fail(1, fmt.Errorf("front matter opened with %s is never closed", delimiter))
+	}
+
+	var meta PageMeta
+	switch format {
+	case "yaml":
+		if err := yaml.Unmarshal(block, &meta); err != nil {
+			// yaml counts lines from the start of the block, which is line 2.
+			line, msg := yamlErrorLine(err)
+			if line > 0 {
+				line++
+			}
+			return fail(line, fmt.Errorf("invalid YAML front matter: %s", msg))
+		}
+	case "toml":
+		var values map[string]interface{}
+		if _, err := toml.Decode(string(block), &values); err != nil {
+			var parseErr toml.ParseError
+			if errors.As(err, &parseErr) {
+				msg := tomlLine.ReplaceAllString(parseErr.Error(), "")
+				return fail(parseErr.Position.Line+1, fmt.Errorf("invalid TOML front matter: %s", msg))
+			}
+			return fail(0, fmt.Errorf("invalid TOML front matter: %w", err))
+		}
+		decoded, err := decodeFrontMatterValues(values)
+		if err != nil {
+			return fail(0, err)
+		}
+		meta = decoded
+	}
+	return meta, body, nil
+}
+
+// isJSONFrontMatter reports whether content starts with a JSON object: a
+// brace followed by a key or the closing brace. Shortcodes such as
+// {{< figure >}} also start with a brace but are page content.
+func isJSONFrontMatter(content []byte) bool {
+	if !bytes.HasPrefix(content, []byte("{")) {
+		return false
+	}
+	next := bytes.TrimLeft(content[1:], " \t\r\n")
+	return len(next) > 0 && (next[0] == '"' || next[0] == '}')
+}
+
+// decodeFrontMatterValues fills a PageMeta from TOML or JSON values by
+// passing them through the same YAML decoding as YAML front matter, so
+// dates and unknown params behave identically in every format.
+func decodeFrontMatterValues(values map[string]interface{}) (PageMeta, error) {
+	var node yaml.Node
+	if err := node.Encode(values); err != nil {
+		return PageMeta{}, err
+	}
+	var meta PageMeta
+	if err := node.Decode(&meta); err != nil {
+		_, msg := yamlErrorLine(err)
+		return PageMeta{}, fmt.Errorf("invalid front matter: %s", msg)
+	}
+	return meta, nil
+}
+
+// cutLine splits b after its first newline.
+func cutLine(b []byte) (line, rest []byte) {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i+1], b[i+1:]
+	}
+	return b, nil
+}
+
+var (
+	yamlLine = regexp.MustCompile(`^(?:yaml: )?(?:unmarshal errors:\s*)?line (\d+): `)
+	tomlLine = regexp.MustCompile(`^toml: line \d+(?: \(last key "[^"]*"\))?: `)
+)
+
+// yamlErrorLine pulls the line number out of a yaml error message and
+// returns the message without it. Encoded values have no lines, so line 0
+// is treated as unknown.
+func yamlErrorLine(err error) (int, string) {
+	msg := err.Error()
+	m := yamlLine.FindStringSubmatch(msg)
+	if m == nil {
+		return 0, msg
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line, msg[len(m[0]):]
+}
+
+// jsonErrorLine returns the line of the JSON input a decoding error refers to.
+func jsonErrorLine(content []byte, err error) int {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return 0
+	}
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
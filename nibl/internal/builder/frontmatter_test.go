@@ -0,0 +1,51 @@
+// internal/builder/frontmatter_test.go
+package builder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		title   string
+		body    string
+		wantErr string
+	}{
+		{name: "yaml", raw: "---\ntitle: Mara\n---\nBody\n", title: "Mara", body: "Body\n"},
+		{name: "toml", raw: "+++\ntitle = \"Mara\"\n+++\nBody\n", title: "Mara", body: "Body\n"},
+		{name: "json", raw: "{\"title\": \"Mara\"}\nBody\n", title: "Mara", body: "\nBody\n"},
+		{name: "json with leading space", raw: "{\n  \"title\": \"Mara\"\n}\nBody", title: "Mara", body: "\nBody"},
+		{name: "empty json", raw: "{}\nBody", body: "\nBody"},
+		{name: "byte order mark", raw: "\xef\xbb\xbf---\ntitle: Mara\n---\nBody", title: "Mara", body: "Body"},
+		{name: "no front matter", raw: "Just text\n---\nmore", body: "Just text\n---\nmore"},
+		{name: "shortcode on the first line", raw: "{{< figure src=\"a.jpg\" >}}\nText", body: "{{< figure src=\"a.jpg\" >}}\nText"},
+		{name: "brace in prose", raw: "{ an aside }\nText", body: "{ an aside }\nText"},
+		{name: "rule later in the body", raw: "---\ntitle: A\n---\nOne\n---\nTwo", title: "A", body: "One\n---\nTwo"},
+		{name: "unclosed", raw: "---\ntitle: A\nBody", wantErr: "a.md:1: front matter opened with --- is never closed"},
+		{name: "bad yaml", raw: "---\ntitle: A\n  bad: [\n---\n", wantErr: "a.md:"},
+		{name: "bad json", raw: "{\"title\": }\n", wantErr: "invalid JSON front matter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, body, err := splitFrontMatter("a.md", []byte(tt.raw))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if meta.Title != tt.title {
+				t.Errorf("title = %q, want %q", meta.Title, tt.title)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// internal/builder/funcs.go
+package builder
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"nibl/internal/config"
+	"nibl/internal/util"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// templateFuncs backs the functions available to theme templates and
+// archetypes. Functions that need the site, such as getPage, see it once
+// the build has collected its pages; until then they find nothing.
+type templateFuncs struct {
+	site   SiteData
+	byPath map[string]*Page
+	assets assetSet
+	images *imageProcessor
+
+	translations map[string]map[string]string // The theme's i18n tables by language code
+}
+
+// setSite makes the pages of the build in progress available to lookups.
+// It must be called before rendering starts, as rendering runs in parallel.
+func (f *templateFuncs) setSite(site SiteData) {
+	f.site = site
+	f.byPath = make(map[string]*Page, len(site.Pages)*3)
+	for _, page := range site.Pages {
+		f.byPath[filepath.ToSlash(page.relPath)] = page
+		f.byPath[page.slug()] = page
+		// Within a language, its pages are also found without the language.
+		f.byPath[page.langSlug()] = page
+	}
+}
+
+// funcMap lists the template functions:
+//
+//	markdownify  renders a markdown string to HTML
+//	slugify      turns text into a URL-safe path segment
+//	dateFormat   formats a date with a Go layout: dateFormat "2 Jan 2006" .Date
+//	now          the current time, e.g. for archetypes: now | dateFormat "2006-01-02"
+//	relURL       links a site path from the current page: relURL $ "css/site.css"
+//	summary      a listed page's summary, its links rewritten for the current page: {{ range .Pages }}{{ summary $ . }}{{ end }}
+//	absURL       prefixes a site path with baseurl: absURL "feed.xml"
+//	plainify     strips HTML tags: .Content | plainify
+//	truncate     shortens text to n characters at a word boundary: truncate 80 .Description
+//	countwords   counts the words of text or HTML
+//	safeHTML     marks a string as trusted HTML, skipping escaping
+//	getPage      finds a page by content path: getPage "book/ch1.md"
+//	asset        the published name of a static CSS or JS file: relURL $ (asset "css/style.css")
+//	integrity    its Subresource Integrity attributes, when enabled: <link ...{{ integrity "css/style.css" }}>
+//	image        an image from static/ to resize, crop or convert: {{ $img := (image "images/map.jpg").Resize 800 }}
+//	srcset       a srcset of resized copies: <img srcset="{{ srcset $ $img 480 800 }}" ...>
+//	i18n         a string from the theme's i18n table for the current language: {{ i18n "home" }}
+func (f *templateFuncs) funcMap() map[string]interface{} {
+	return map[string]interface{}{
+		"markdownify": f.markdownify,
+		"slugify":     func(s interface{}) string { return util.Slugify(fmt.Sprint(s)) },
+		"dateFormat":  dateFormat,
+		"now":         time.Now,
+		"relURL":      relURL,
+		"summary":     summaryFor,
+		"absURL":      func(target string) string { return util.AbsURL(f.site.BaseURL, target) },
+		"plainify":    plainify,
+		"truncate":    truncate,
+		"countwords":  countWords,
+		"safeHTML":    func(s interface{}) template.HTML { return template.HTML(fmt.Sprint(s)) },
+		"getPage":     f.getPage,
+		"asset":       func(rel string) string { return f.assets.path(rel) },
+		"integrity":   func(rel string) template.HTMLAttr { return f.assets.integrity(rel) },
+		"image":       f.image,
+		"srcset":      f.srcset,
+		"i18n":        f.i18n,
+	}
+}
+
+// ArchetypeFuncs returns the template functions for archetypes, which are
+// executed before any page exists, so getPage always returns nil there.
+// Functions that link from the page being rendered, relURL, summary and
+// srcset, are left out, as an archetype has no such page.
+func ArchetypeFuncs(site config.SiteConfig) map[string]interface{} {
+	funcs := &templateFuncs{site: SiteData{SiteConfig: site, markdown: newMarkdownRenderer(site), sanitizer: newSanitizer(site.Sanitize, false)}}
+	funcMap := funcs.funcMap()
+	for _, name := range []string{"relURL", "summary", "srcset"} {
+		delete(funcMap, name)
+	}
+	return funcMap
+}
+
+// markdownify renders inline markdown such as a description. A single
+// paragraph is unwrapped so the result can sit inside other elements.
+func (f *templateFuncs) markdownify(s interface{}) (template.HTML, error) {
+	policy, err := f.site.sanitizer.policy(trustDefault)
+	if err != nil {
+		return "", err
+	}
+	content, err := processContent(f.site.markdown, []byte(fmt.Sprint(s)), policy, nil)
+	if err != nil {
+		return "", err
+	}
+	out := strings.TrimSpace(content.html)
+	if strings.HasPrefix(out, "<p>") && strings.HasSuffix(out, "</p>") && strings.Count(out, "<p>") == 1 {
+		out = out[len("<p>") : len(out)-len("</p>")]
+	}
+	return template.HTML(out), nil
+}
+
+// image opens an image below static/ for processing.
+func (f *templateFuncs) image(src string) (*Image, error) {
+	if f.images == nil {
+		return nil, fmt.Errorf("image %s: images can only be used in layouts and shortcodes", src)
+	}
+	return f.images.image(src)
+}
+
+// srcset resizes img to each width, or to the configured widths when none
+// are given, and lists the copies for an <img srcset> attribute.
+func (f *templateFuncs) srcset(page PageData, img *Image, widths ...int) (string, error) {
+	if f.images == nil {
+		return "", fmt.Errorf("srcset can only be used in layouts and shortcodes")
+	}
+	return f.images.srcset(page, img, widths)
+}
+
+// i18n looks a key up in the string table of the language being built, then
+// in that of the default language. Keys found in neither are returned as
+// they are, so templates stay readable while a table is incomplete.
+func (f *templateFuncs) i18n(key string) string {
+	codes := []string{f.site.Language.Code}
+	if len(f.site.Languages) > 0 {
+		codes = append(codes, f.site.Languages[0].Code)
+	}
+	for _, code := range codes {
+		if s, ok := f.translations[code][key]; ok {
+			return s
+		}
+	}
+	return key
+}
+
+// getPage looks a page up by its path under content/, with or without the
+// file extension, e.g. "book/ch1.md" or "book/ch1". Only pages of the
+// language being built are found.
+func (f *templateFuncs) getPage(p string) *Page {
+	return f.byPath[strings.TrimPrefix(path.Clean("/"+p), "/")]
+}
+
+// dateFormat formats a front matter date, a time.Time or a date string.
+// Zero dates format as the empty string.
+func dateFormat(layout string, v interface{}) (string, error) {
+	var t time.Time
+	switch d := v.(type) {
+	case Date:
+		t = d.Time
+	case time.Time:
+		t = d
+	case string:
+		parsed, err := parseDate(d)
+		if err != nil {
+			return "", err
+		}
+		t = parsed.Time
+	default:
+		return "", fmt.Errorf("dateFormat: cannot format %T as a date", v)
+	}
+	if t.IsZero() {
+		return "", nil
+	}
+	return t.Format(layout), nil
+}
+
+// relURL returns a site path relative to the page being rendered, so the
+// output keeps working wherever the site is opened from. Absolute URLs such
+// as "https://..." or "//cdn.example.com/x.js" are returned unchanged.
+func relURL(page PageData, target string) string {
+	if u, err := url.Parse(target); err == nil && (u.Scheme != "" || u.Host != "") {
+		return target
+	}
+	return page.BaseHref + strings.TrimPrefix(target, "/")
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// plainify strips HTML tags and unescapes entities.
+func plainify(s interface{}) string {
+	return html.UnescapeString(htmlTag.ReplaceAllString(fmt.Sprint(s), ""))
+}
+
+// truncate shortens s to at most n characters, cutting at the last word
+// boundary and adding an ellipsis. HTML input is plainified first; a
+// negative n counts as 0.
+func truncate(n int, s interface{}) string {
+	if n < 0 {
+		n = 0
+	}
+	text := strings.TrimSpace(plainify(s))
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:n])
+	if i := strings.LastIndexAny(cut, " \t\n"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \t\n.,;:") + "…"
+}
+
+// countWords counts the whitespace-separated words of text or HTML.
+func countWords(s interface{}) int {
+	return len(strings.Fields(plainify(s)))
+}
@@ -0,0 +1,83 @@
+// internal/builder/funcs_test.go
+package builder
+
+import (
+	"nibl/internal/config"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n    int
+		in   string
+		want string
+	}{
+		{n: 20, in: "short", want: "short"},
+		{n: 12, in: "The quick brown fox", want: "The quick…"},
+		{n: 9, in: "<p>Año <em>nuevo</em>, vida nueva</p>", want: "Año…"},
+		{n: 3, in: "Unbreakable", want: "Unb…"},
+		{n: 0, in: "text", want: "…"},
+		{n: -5, in: "text", want: "…"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.n, tt.in); got != tt.want {
+			t.Errorf("truncate(%d, %q) = %q, want %q", tt.n, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRelURL(t *testing.T) {
+	page := PageData{BaseHref: "../../"}
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{target: "css/site.css", want: "../../css/site.css"},
+		{target: "/css/site.css", want: "../../css/site.css"},
+		{target: "https://example.com/a.js", want: "https://example.com/a.js"},
+		{target: "//cdn.example.com/a.js", want: "//cdn.example.com/a.js"},
+		{target: "mailto:me@example.com", want: "mailto:me@example.com"},
+	}
+	for _, tt := range tests {
+		if got := relURL(page, tt.target); got != tt.want {
+			t.Errorf("relURL(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestArchetypeFuncs(t *testing.T) {
+	funcs := ArchetypeFuncs(config.SiteConfig{BaseURL: "https://example.com/"})
+	tests := []struct {
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{src: `{{ "Mara's Garden" | slugify }}`, want: "maras-garden"},
+		{src: `{{ absURL "index.xml" }}`, want: "https://example.com/index.xml"},
+		{src: `{{ markdownify "*hi*" }}`, want: "<em>hi</em>"},
+		{src: `{{ getPage "a.md" }}`, want: "<nil>"},
+		{src: `{{ relURL $ "css/site.css" }}`, wantErr: true},
+		{src: `{{ summary $ . }}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		tmpl, err := template.New("archetype").Funcs(funcs).Parse(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: parsed, want an undefined function", tt.src)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, nil); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s = %q, want %q", tt.src, b.String(), tt.want)
+		}
+	}
+}
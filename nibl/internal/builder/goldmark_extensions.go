@@ -3,11 +3,57 @@ package builder
 
 import (
 	"bytes"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
 )
 
+// linkResolverKey carries the current page's linkResolver through the parser
+// context, since the transformer itself is shared by every page.
+var linkResolverKey = parser.NewContextKey()
+
+// linkResolver maps the .md link destinations of one page to the URLs of the
+// pages they point at, wherever permalinks have placed them.
+type linkResolver struct {
+	sourceDir string            // Slash-separated dir of the page's source, relative to content/
+	baseHref  string            // The page's BaseHref, prefixed to resolved URLs
+	targets   map[string]string // Slash-separated source path relative to content/ -> page URL
+}
+
+// resolve returns the URL for a link destination such as "../forest/glade.md#end".
+// It reports false for links that do not point at a known content page.
+func (r *linkResolver) resolve(dest string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.HasSuffix(u.Path, ".md") {
+		return "", false
+	}
+	source := u.Path
+	if !strings.HasPrefix(source, "/") {
+		source = path.Join(r.sourceDir, source)
+	}
+	target, ok := r.targets[strings.TrimPrefix(path.Clean("/"+source), "/")]
+	if !ok {
+		return "", false
+	}
+	if target == "" && r.baseHref == "" {
+		target = "./"
+	}
+	resolved := r.baseHref + target
+	if u.RawQuery != "" {
+		resolved += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		resolved += "#" + u.Fragment
+	}
+	return resolved, true
+}
+
 // mdLinkTransformer is a struct that implements the goldmark ASTTransformer interface.
 // Its purpose is to walk the document's Abstract Syntax Tree (AST) and modify link nodes.
 type mdLinkTransformer struct {
@@ -21,6 +67,8 @@ func newMDLinkTransformer() parser.ASTTransformer {
 // Transform is the method called by Goldmark to apply our custom logic.
 // It walks the AST and calls a function for each node.
 func (t *mdLinkTransformer) Transform(node *ast.Document, reader text.Reader, pc parser.Context) {
+	resolver, _ := pc.Get(linkResolverKey).(*linkResolver)
+
 	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
 		// We only need to process nodes when "entering" them during the walk.
 		if !entering {
@@ -34,16 +82,95 @@ func (t *mdLinkTransformer) Transform(node *ast.Document, reader text.Reader, pc
 			return ast.WalkContinue, nil
 		}
 
+		// Links to content pages point wherever permalinks put the target.
+		if resolver != nil {
+			if resolved, ok := resolver.resolve(string(link.Destination)); ok {
+				link.Destination = []byte(resolved)
+				return ast.WalkContinue, nil
+			}
+		}
+
 		// Get the link destination (the URL part).
 		dest := link.Destination
 		// Check if the destination ends with ".md".
 		if bytes.HasSuffix(dest, []byte(".md")) {
-			// If it does, replace the .md extension with .html.
-			newDest := bytes.TrimSuffix(dest, []byte(".md"))
-			newDest = append(newDest, []byte(".html")...)
-			link.Destination = newDest
+			// If it does, replace the .md extension with .html. The new
+			// destination gets its own slice: dest points into the source,
+			// and appending to it would overwrite the text after the link.
+			newDest := append([]byte(nil), bytes.TrimSuffix(dest, []byte(".md"))...)
+			link.Destination = append(newDest, ".html"...)
 		}
 		return ast.WalkContinue, nil
 	})
 }
 
+// headingsKey holds the headings the collector found, as []*Heading.
+var headingsKey = parser.NewContextKey()
+
+// headingIDs generates the ids of headings like goldmark's default, but
+// keeps letters and digits of every script, so "Capítulo uno" becomes
+// "capítulo-uno" rather than "captulo-uno". It is used for one document.
+type headingIDs struct {
+	values map[string]bool
+}
+
+func newHeadingIDs() parser.IDs {
+	return &headingIDs{values: make(map[string]bool)}
+}
+
+func (s *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var id []rune
+	for _, r := range string(bytes.TrimSpace(value)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			id = append(id, unicode.ToLower(r))
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			id = append(id, '-')
+		}
+	}
+	result := string(id)
+	if result == "" {
+		result = "id"
+		if kind == ast.KindHeading {
+			result = "heading"
+		}
+	}
+	unique := result
+	for i := 1; s.values[unique]; i++ {
+		unique = result + "-" + strconv.Itoa(i)
+	}
+	s.values[unique] = true
+	return []byte(unique)
+}
+
+// Put records an id that is already taken.
+func (s *headingIDs) Put(value []byte) {
+	s.values[string(value)] = true
+}
+
+// headingCollector records every heading of a document with the id that
+// parser.WithAutoHeadingID gave it, for the page's table of contents.
+type headingCollector struct{}
+
+func newHeadingCollector() parser.ASTTransformer {
+	return &headingCollector{}
+}
+
+func (c *headingCollector) Transform(node *ast.Document, reader text.Reader, pc parser.Context) {
+	var headings []*Heading
+	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		h := &Heading{Level: heading.Level, Title: string(heading.Text(reader.Source()))}
+		if id, ok := heading.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok {
+				h.ID = string(b)
+			}
+		}
+		headings = append(headings, h)
+		return ast.WalkSkipChildren, nil
+	})
+	pc.Set(headingsKey, headings)
+}
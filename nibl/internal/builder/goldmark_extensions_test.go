@@ -0,0 +1,91 @@
+// internal/builder/goldmark_extensions_test.go
+package builder
+
+import (
+	"nibl/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownLinks(t *testing.T) {
+	md := newMarkdownRenderer(config.SiteConfig{})
+	links := &linkResolver{
+		sourceDir: "book",
+		targets: map[string]string{
+			"book/ch2.md": "book/2024/ch2/",
+			"about.md":    "about.html",
+		},
+	}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "resolved", src: "[two](ch2.md#top) and more", want: `<p><a href="book/2024/ch2/#top">two</a> and more</p>`},
+		{name: "parent directory", src: "[about](../about.md) page", want: `<p><a href="about.html">about</a> page</p>`},
+		{name: "unresolved", src: "[gone](gone.md) and more", want: `<p><a href="gone.html">gone</a> and more</p>`},
+		{
+			name: "several unresolved",
+			src:  "Go to [one](one.md) or [two](two.md), then on.",
+			want: `<p>Go to <a href="one.html">one</a> or <a href="two.html">two</a>, then on.</p>`,
+		},
+		{name: "other links untouched", src: "[page](page.html) and more", want: `<p><a href="page.html">page</a> and more</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := processContent(md, []byte(tt.src), nil, links)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(content.html); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslationLinks(t *testing.T) {
+	languages := []Language{{Code: "en"}, {Code: "es", Dir: "es/"}}
+	var pages []*Page
+	for _, rel := range []string{"book/ch1.md", "book/ch2.md", "book/ch1.es.md", "book/ch2.es.md", "es/book/ch3.md", "es/book/ch4.md"} {
+		pages = append(pages, testPage(rel, PageMeta{}))
+	}
+	assignLanguages(pages, languages)
+	if err := resolveOutputs(pages, config.SiteConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	targets := make(map[string]string)
+	byPath := make(map[string]*Page)
+	for _, page := range pages {
+		targets[page.relPath] = page.URL
+		byPath[page.relPath] = page
+	}
+	site := SiteData{markdown: newMarkdownRenderer(config.SiteConfig{}), sanitizer: newSanitizer(config.SanitizeConfig{}, false)}
+
+	tests := []struct {
+		name string
+		page string // Source of the link
+		link string
+		want string
+	}{
+		{name: "suffix to suffix", page: "book/ch1.es.md", link: "ch2.es.md", want: "../../es/book/ch2.html"},
+		{name: "suffix to default language", page: "book/ch1.es.md", link: "ch1.md", want: "../../book/ch1.html"},
+		{name: "suffix to directory", page: "book/ch1.es.md", link: "../es/book/ch3.md", want: "../../es/book/ch3.html"},
+		{name: "directory to directory", page: "es/book/ch3.md", link: "ch4.md", want: "../../es/book/ch4.html"},
+		{name: "directory to suffix", page: "es/book/ch3.md", link: "../../book/ch2.es.md", want: "../../es/book/ch2.html"},
+		{name: "directory to default language", page: "es/book/ch3.md", link: "../../book/ch1.md", want: "../../book/ch1.html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := byPath[tt.page]
+			page.body = []byte("[x](" + tt.link + ")")
+			content, err := renderContent(page, targets, site, &Theme{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := `<a href="` + tt.want + `"`; !strings.Contains(string(content.html), want) {
+				t.Errorf("got %s, want a link to %s", content.html, tt.want)
+			}
+		})
+	}
+}
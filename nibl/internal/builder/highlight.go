@@ -0,0 +1,134 @@
+// internal/builder/highlight.go
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"nibl/internal/config"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+)
+
+const (
+	defaultHighlightStyle      = "github"
+	defaultHighlightStylesheet = "css/highlight.css"
+)
+
+// highlightOptions configures goldmark to highlight fenced code blocks with
+// CSS classes instead of inline styles, so the sanitizer can keep them and
+// the colours live in one stylesheet. Blocks without a known language are
+// left as plain <pre><code>.
+func highlightOptions(cfg config.HighlightConfig) []goldmark.Option {
+	if cfg.Disabled {
+		return nil
+	}
+	return []goldmark.Option{goldmark.WithExtensions(highlighting.NewHighlighting(
+		highlighting.WithGuessLanguage(false),
+		highlighting.WithFormatOptions(
+			chromahtml.WithClasses(true),
+			chromahtml.WithLineNumbers(cfg.LineNumbers),
+			chromahtml.LineNumbersInTable(true),
+		),
+	))}
+}
+
+// highlightStylesheet returns the output path of the stylesheet for
+// highlighted code, "" when highlighting is disabled.
+func highlightStylesheet(cfg config.HighlightConfig) string {
+	if cfg.Disabled {
+		return ""
+	}
+	url := cfg.Stylesheet
+	if url == "" {
+		url = defaultHighlightStylesheet
+	}
+	return path.Clean("/" + url)[1:]
+}
+
+// writeHighlightCSS writes the stylesheet for highlighted code and returns
+// its output path. Themes link it with
+// {{ with .Site.HighlightCSS }}<link rel="stylesheet" href="{{ $.BaseHref }}{{ . }}">{{ end }}.
+func writeHighlightCSS(outputDir string, cfg config.HighlightConfig) (string, error) {
+	if cfg.Disabled {
+		return "", nil
+	}
+	name := cfg.Style
+	if name == "" {
+		name = defaultHighlightStyle
+	}
+	style, ok := styles.Registry[name]
+	if !ok {
+		fmt.Printf("Warning: unknown highlight style %q, using %q.\n", name, defaultHighlightStyle)
+		style = styles.Get(defaultHighlightStyle)
+	}
+	url := highlightStylesheet(cfg)
+
+	var css bytes.Buffer
+	formatter := chromahtml.New(chromahtml.WithClasses(true), chromahtml.WithLineNumbers(cfg.LineNumbers))
+	if err := formatter.WriteCSS(&css, style); err != nil {
+		return "", fmt.Errorf("failed to generate highlight stylesheet: %w", err)
+	}
+	outputPath := filepath.Join(outputDir, filepath.FromSlash(url))
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return "", err
+	}
+	return url, os.WriteFile(outputPath, css.Bytes(), 0644)
+}
+
+// editmlRules mark up EditML's inline edits: {+insertions+}, {-deletions-},
+// {~substitutions~>...~}, {>comments<} and {=highlights=}. They are shared by
+// the biff lexer, since knot bodies are written in EditML.
+func editmlRules() []chroma.Rule {
+	return []chroma.Rule{
+		{Pattern: `\{\+{1,2}[\s\S]*?\+{1,2}\}`, Type: chroma.GenericInserted},
+		{Pattern: `\{-{1,2}[\s\S]*?-{1,2}\}`, Type: chroma.GenericDeleted},
+		{Pattern: `\{~{1,2}[\s\S]*?~{1,2}\}`, Type: chroma.GenericEmph},
+		{Pattern: `\{>{1,2}[\s\S]*?<{1,2}\}`, Type: chroma.Comment},
+		{Pattern: `\{={1,2}[\s\S]*?={1,2}\}`, Type: chroma.GenericStrong},
+	}
+}
+
+func init() {
+	lexers.Register(chroma.MustNewLexer(
+		&chroma.Config{Name: "EditML", Aliases: []string{"editml"}, Filenames: []string{"*.editml"}},
+		func() chroma.Rules {
+			return chroma.Rules{
+				"root": append(editmlRules(),
+					chroma.Rule{Pattern: `[^{]+`, Type: chroma.Text},
+					chroma.Rule{Pattern: `\{`, Type: chroma.Text},
+				),
+			}
+		},
+	))
+
+	lexers.Register(chroma.MustNewLexer(
+		&chroma.Config{Name: "biff", Aliases: []string{"biff"}, Filenames: []string{"*.biff"}, EnsureNL: true},
+		func() chroma.Rules {
+			return chroma.Rules{
+				"root": append([]chroma.Rule{
+					// `// key: value` metadata, then plain comments.
+					{Pattern: `^(//)([ \t]*)([\w-]+)(:)(.*\n)`, Type: chroma.ByGroups(chroma.CommentSingle, chroma.Text, chroma.NameAttribute, chroma.Punctuation, chroma.LiteralString)},
+					{Pattern: `//.*\n`, Type: chroma.CommentSingle},
+					// === knot ===
+					{Pattern: `^(===)([ \t]*)([\w.-]+)([ \t]*)(===)`, Type: chroma.ByGroups(chroma.Keyword, chroma.Text, chroma.NameLabel, chroma.Text, chroma.Keyword)},
+					// Choices (* text -> target) and conditional lines (- {cond}).
+					{Pattern: `^([ \t]*)([*+])([ \t])`, Type: chroma.ByGroups(chroma.Text, chroma.Keyword, chroma.Text)},
+					{Pattern: `(->)([ \t]*)([\w.-]+)`, Type: chroma.ByGroups(chroma.Operator, chroma.Text, chroma.NameLabel)},
+					{Pattern: `^([ \t]*)(-)([ \t]*)(\{[^}\n]*\})`, Type: chroma.ByGroups(chroma.Text, chroma.Keyword, chroma.Text, chroma.NameVariable)},
+					{Pattern: `^END\b`, Type: chroma.KeywordReserved},
+				}, append(editmlRules(),
+					chroma.Rule{Pattern: `[^{\n/-]+`, Type: chroma.Text},
+					chroma.Rule{Pattern: `[\s\S]`, Type: chroma.Text},
+				)...),
+			}
+		},
+	))
+}
@@ -0,0 +1,372 @@
+// internal/builder/images.go
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"nibl/internal/config"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Decode .webp sources
+)
+
+const (
+	defaultImageQuality = 80
+	imageCacheSubdir    = "images"
+)
+
+var defaultImageWidths = []int{480, 800, 1200}
+
+// imageProcessor resizes, crops and re-encodes images from static/ on
+// behalf of templates. Results are cached in the cache dir under a key made
+// of the source's hash and the processing parameters, so later builds only
+// copy them. It is safe for concurrent use by pages rendered in parallel.
+type imageProcessor struct {
+	staticDir string
+	outputDir string
+	cacheDir  string // Empty to disable the cache
+	cfg       config.ImageConfig
+
+	mu      sync.Mutex
+	sources map[string]*imageSource
+	jobs    map[string]*imageJob
+	written map[string]bool
+}
+
+// imageSource is a decoded-on-demand source image.
+type imageSource struct {
+	once          sync.Once
+	err           error
+	hash          string
+	width, height int
+	format        string
+}
+
+// imageJob makes sure each variant is produced once per build.
+type imageJob struct {
+	once sync.Once
+	err  error
+}
+
+func newImageProcessor(staticDir, outputDir, cacheDir string, cfg config.ImageConfig) *imageProcessor {
+	return &imageProcessor{
+		staticDir: staticDir,
+		outputDir: outputDir,
+		cacheDir:  cacheDir,
+		cfg:       cfg,
+		sources:   make(map[string]*imageSource),
+		jobs:      make(map[string]*imageJob),
+		written:   make(map[string]bool),
+	}
+}
+
+// Image is an image from static/, possibly with processing steps applied.
+// Templates start with {{ $img := image "images/map.jpg" }} and chain
+// .Resize, .Crop and .Convert; .URL writes the variant and returns its path.
+type Image struct {
+	Width, Height int // Dimensions after processing
+
+	proc   *imageProcessor
+	src    string // Slash path below static/
+	source *imageSource
+	crop   bool
+	format string // jpeg, png or gif
+}
+
+// image loads the dimensions of a source image below static/.
+func (p *imageProcessor) image(src string) (*Image, error) {
+	src = strings.TrimPrefix(path.Clean("/"+src), "/")
+	p.mu.Lock()
+	source, ok := p.sources[src]
+	if !ok {
+		source = &imageSource{}
+		p.sources[src] = source
+	}
+	p.mu.Unlock()
+
+	source.once.Do(func() { source.err = source.load(filepath.Join(p.staticDir, filepath.FromSlash(src))) })
+	if source.err != nil {
+		return nil, fmt.Errorf("image %s: %w", src, source.err)
+	}
+	format := source.format
+	if format == "webp" {
+		format = "jpeg" // No pure Go WebP encoder; publish as JPEG
+	}
+	return &Image{Width: source.width, Height: source.height, proc: p, src: src, source: source, format: format}, nil
+}
+
+func (s *imageSource) load(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	cfg, format, err := image.DecodeConfig(io.TeeReader(f, h))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	s.hash = hex.EncodeToString(h.Sum(nil))
+	s.width, s.height, s.format = cfg.Width, cfg.Height, format
+	return nil
+}
+
+// Resize scales the image to the given width, keeping its aspect ratio.
+// Images are never enlarged.
+func (img Image) Resize(width int) (*Image, error) {
+	if width <= 0 {
+		return nil, fmt.Errorf("image %s: invalid width %d", img.src, width)
+	}
+	if width < img.Width {
+		img.Height = int(math.Round(float64(img.Height) * float64(width) / float64(img.Width)))
+		img.Width = width
+	}
+	return &img, nil
+}
+
+// Crop scales and crops the image to fill exactly the given size, e.g.
+// "400x300", keeping the centre.
+func (img Image) Crop(size string) (*Image, error) {
+	w, h, err := parseImageSize(size)
+	if err != nil {
+		return nil, fmt.Errorf("image %s: %w", img.src, err)
+	}
+	img.Width, img.Height, img.crop = w, h, true
+	return &img, nil
+}
+
+// Convert re-encodes the image as "jpeg" (or "jpg"), "png" or "gif".
+func (img Image) Convert(format string) (*Image, error) {
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg":
+		img.format = "jpeg"
+	case "png", "gif":
+		img.format = strings.ToLower(format)
+	default:
+		return nil, fmt.Errorf("image %s: cannot encode as %q", img.src, format)
+	}
+	return &img, nil
+}
+
+// URL produces the processed image and returns its path relative to the
+// site root. Unprocessed images return the path of the original file.
+func (img *Image) URL() (string, error) {
+	if !img.processed() {
+		return img.src, nil
+	}
+	return img.proc.produce(img)
+}
+
+func (img *Image) processed() bool {
+	return img.crop || img.Width != img.source.width || img.Height != img.source.height || img.format != img.source.format
+}
+
+// key identifies a variant by its source content and parameters.
+func (img *Image) key() string {
+	spec := fmt.Sprintf("%s|%dx%d|crop=%t|%s|q=%d", img.source.hash, img.Width, img.Height, img.crop, img.format, img.proc.quality())
+	sum := sha256.Sum256([]byte(spec))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// output is the variant's path below the output directory, e.g.
+// "images/map_800x533_1a2b3c4d.jpg".
+func (img *Image) output() string {
+	ext := map[string]string{"jpeg": ".jpg", "png": ".png", "gif": ".gif"}[img.format]
+	base := strings.TrimSuffix(img.src, path.Ext(img.src))
+	return fmt.Sprintf("%s_%dx%d_%s%s", base, img.Width, img.Height, img.key()[:8], ext)
+}
+
+// produce writes a variant to the output directory, from the cache when possible.
+func (p *imageProcessor) produce(img *Image) (string, error) {
+	out := img.output()
+	p.mu.Lock()
+	job, ok := p.jobs[out]
+	if !ok {
+		job = &imageJob{}
+		p.jobs[out] = job
+	}
+	p.mu.Unlock()
+
+	job.once.Do(func() {
+		job.err = p.render(img, filepath.Join(p.outputDir, filepath.FromSlash(out)))
+		if job.err == nil {
+			p.mu.Lock()
+			p.written[out] = true
+			p.mu.Unlock()
+		}
+	})
+	if job.err != nil {
+		return "", fmt.Errorf("image %s: %w", img.src, job.err)
+	}
+	return out, nil
+}
+
+func (p *imageProcessor) render(img *Image, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if p.cacheDir == "" {
+		return p.encode(img, dest)
+	}
+	cached := filepath.Join(p.cacheDir, imageCacheSubdir, img.key()+path.Ext(dest))
+	if _, err := os.Stat(cached); err != nil {
+		if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+			return err
+		}
+		// Encode to a temporary name so an interrupted build leaves no broken entry.
+		tmp := cached + ".tmp"
+		if err := p.encode(img, tmp); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, cached); err != nil {
+			return err
+		}
+	}
+	info, err := os.Stat(cached)
+	if err != nil {
+		return err
+	}
+	return copyIfChanged(cached, dest, info)
+}
+
+// encode decodes the source, applies the processing and writes the result.
+func (p *imageProcessor) encode(img *Image, dest string) error {
+	f, err := os.Open(filepath.Join(p.staticDir, filepath.FromSlash(img.src)))
+	if err != nil {
+		return err
+	}
+	src, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	bounds := src.Bounds()
+	if img.crop {
+		bounds = cropBounds(bounds, img.Width, img.Height)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, img.Width, img.Height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	switch img.format {
+	case "png":
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(out, dst)
+	case "gif":
+		err = gif.Encode(out, dst, nil)
+	default:
+		err = jpeg.Encode(out, dst, &jpeg.Options{Quality: p.quality()})
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (p *imageProcessor) quality() int {
+	if p.cfg.Quality > 0 && p.cfg.Quality <= 100 {
+		return p.cfg.Quality
+	}
+	return defaultImageQuality
+}
+
+// outputs returns every variant written during this build.
+func (p *imageProcessor) outputs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	outputs := make([]string, 0, len(p.written))
+	for out := range p.written {
+		outputs = append(outputs, out)
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+// files returns the hashes of the source images processed during this build.
+func (p *imageProcessor) files() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	files := make(map[string]string, len(p.sources))
+	for src, source := range p.sources {
+		if source.hash != "" {
+			files[src] = source.hash
+		}
+	}
+	return files
+}
+
+// srcset returns the srcset attribute value for img in the given widths,
+// linked from the page being rendered. Widths beyond the image's own are
+// replaced by the image at full width.
+func (p *imageProcessor) srcset(page PageData, img *Image, widths []int) (string, error) {
+	if len(widths) == 0 {
+		widths = p.cfg.Widths
+	}
+	if len(widths) == 0 {
+		widths = defaultImageWidths
+	}
+	var entries []string
+	seen := make(map[int]bool)
+	for _, width := range widths {
+		variant, err := img.Resize(width)
+		if err != nil {
+			return "", err
+		}
+		if seen[variant.Width] {
+			continue
+		}
+		seen[variant.Width] = true
+		url, err := variant.URL()
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, relURL(page, url)+" "+strconv.Itoa(variant.Width)+"w")
+	}
+	return strings.Join(entries, ", "), nil
+}
+
+// cropBounds returns the largest centred rectangle of b with the aspect
+// ratio w:h.
+func cropBounds(b image.Rectangle, w, h int) image.Rectangle {
+	bw, bh := b.Dx(), b.Dy()
+	if bw*h > bh*w {
+		cw := bh * w / h
+		x := b.Min.X + (bw-cw)/2
+		return image.Rect(x, b.Min.Y, x+cw, b.Max.Y)
+	}
+	ch := bw * h / w
+	y := b.Min.Y + (bh-ch)/2
+	return image.Rect(b.Min.X, y, b.Max.X, y+ch)
+}
+
+// parseImageSize parses "WIDTHxHEIGHT".
+func parseImageSize(size string) (int, int, error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(size), "x")
+	w, errW := strconv.Atoi(strings.TrimSpace(ws))
+	h, errH := strconv.Atoi(strings.TrimSpace(hs))
+	if !ok || errW != nil || errH != nil || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q, expected e.g. \"400x300\"", size)
+	}
+	return w, h, nil
+}
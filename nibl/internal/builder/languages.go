@@ -0,0 +1,162 @@
+// internal/builder/languages.go
+package builder
+
+import (
+	"fmt"
+	"nibl/internal/config"
+	"path"
+	"strings"
+)
+
+// Language is one language of a multilingual site. Templates see the one
+// being built as .Site.Language and every language as .Site.Languages.
+type Language struct {
+	Code string // e.g. "es"
+	Name string // e.g. "Español", the code if site.yaml gives no name
+	Dir  string // Output directory below the site root, e.g. "es/", "" for the default language
+}
+
+// siteLanguages returns the languages declared in site.yaml, the default
+// first. A site without `languages:` has a single, unnamed language.
+func siteLanguages(site config.SiteConfig) ([]Language, error) {
+	if len(site.Languages) == 0 {
+		return []Language{{}}, nil
+	}
+	languages := make([]Language, 0, len(site.Languages))
+	seen := make(map[string]bool)
+	for i, cfg := range site.Languages {
+		code := strings.TrimSpace(cfg.Code)
+		switch {
+		case code == "":
+			return nil, fmt.Errorf("language %d in site.yaml has no code", i+1)
+		case strings.ContainsAny(code, "/\\."):
+			return nil, fmt.Errorf("invalid language code %q", code)
+		case seen[code]:
+			return nil, fmt.Errorf("language %q is declared twice", code)
+		}
+		seen[code] = true
+		lang := Language{Code: code, Name: cfg.Name}
+		if lang.Name == "" {
+			lang.Name = code
+		}
+		if i > 0 {
+			lang.Dir = code + "/"
+		}
+		languages = append(languages, lang)
+	}
+	return languages, nil
+}
+
+// localize returns the site configuration as seen from one language, with
+// the title and description site.yaml gives for it.
+func localize(site config.SiteConfig, lang Language) config.SiteConfig {
+	for _, cfg := range site.Languages {
+		if strings.TrimSpace(cfg.Code) != lang.Code {
+			continue
+		}
+		if cfg.Title != "" {
+			site.Title = cfg.Title
+		}
+		if cfg.Description != "" {
+			site.Description = cfg.Description
+		}
+	}
+	return site
+}
+
+// assignLanguages works out the language of every page from its path:
+// content/es/ch1.md and content/ch1.es.md are both Spanish, anything else is
+// in the default language. Pages are then placed as if they had been written
+// below their language's directory, so both examples end up at es/ch1.
+func assignLanguages(pages []*Page, languages []Language) {
+	if len(languages) < 2 {
+		return
+	}
+	byCode := make(map[string]Language, len(languages))
+	for _, lang := range languages {
+		byCode[lang.Code] = lang
+	}
+
+	for _, page := range pages {
+		rel := page.logical
+		lang := languages[0]
+		if first, rest, ok := strings.Cut(rel, "/"); ok {
+			if l, found := byCode[first]; found {
+				lang, rel = l, rest
+			}
+		}
+		// A suffix such as chapter.es.md wins over the directory.
+		ext := path.Ext(rel)
+		base := strings.TrimSuffix(rel, ext)
+		if code := strings.TrimPrefix(path.Ext(base), "."); code != "" {
+			if l, found := byCode[code]; found {
+				lang, rel = l, strings.TrimSuffix(base, "."+code)+ext
+			}
+		}
+		page.Language = lang
+		page.logical = lang.Dir + rel
+		page.Section = sectionOf(page.logical)
+	}
+}
+
+// linkTranslations connects the pages that are translations of each other:
+// those with the same path within their language, or the same
+// `translationKey:`. Translations are listed in the order of the languages.
+func linkTranslations(pages []*Page, languages []Language) {
+	if len(languages) < 2 {
+		return
+	}
+	groups := make(map[string]Pages)
+	for _, page := range pages {
+		key := page.TranslationKey
+		if key == "" {
+			key = "path:" + page.langSlug()
+		}
+		groups[key] = append(groups[key], page)
+	}
+	for _, group := range groups {
+		for _, page := range group {
+			page.translations = nil
+			for _, lang := range languages {
+				for _, other := range group {
+					if other != page && other.Language == lang {
+						page.translations = append(page.translations, other)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Translations returns the page in the site's other languages, e.g. for a
+// language menu: {{ range .Translations }}<a href="...">{{ .Language.Name }}</a>{{ end }}
+func (p *Page) Translations() Pages {
+	return p.translations
+}
+
+// langSlug returns the page's slug within its language, e.g. "ch1" for es/ch1.
+func (p *Page) langSlug() string {
+	return strings.TrimPrefix(p.slug(), p.Language.Dir)
+}
+
+// langSection returns the page's section within its language, "" for the
+// language's root.
+func (p *Page) langSection() string {
+	return strings.TrimSuffix(strings.TrimPrefix(p.Section+"/", p.Language.Dir), "/")
+}
+
+// languageRoot is the section holding a language's pages, "" for the default.
+func languageRoot(lang Language) string {
+	return strings.TrimSuffix(lang.Dir, "/")
+}
+
+// pagesIn returns the pages written in a language.
+func pagesIn(pages []*Page, lang Language) []*Page {
+	var in []*Page
+	for _, page := range pages {
+		if page.Language == lang {
+			in = append(in, page)
+		}
+	}
+	return in
+}
@@ -0,0 +1,214 @@
+// internal/builder/linkcheck.go
+package builder
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"nibl/internal/config"
+	"nibl/internal/util"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// BrokenLink is a link in the generated site whose target does not exist.
+type BrokenLink struct {
+	Page   string // Output path of the page holding the link, e.g. "book/ch1.html"
+	Link   string // The link as written in the page
+	Reason string
+}
+
+func (l BrokenLink) String() string {
+	return fmt.Sprintf("%s: %s (%s)", l.Page, l.Link, l.Reason)
+}
+
+// linkAttrs lists the attributes holding links, per element.
+var linkAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"script": {"src"},
+	"iframe": {"src"},
+	"audio":  {"src"},
+	"video":  {"src", "poster"},
+	"track":  {"src"},
+}
+
+// linkedPage holds what the checker needs from one generated HTML file.
+type linkedPage struct {
+	ids   map[string]bool // Anchors: id attributes and <a name>
+	links []string
+}
+
+// CheckLinks parses every HTML file in the output directory and returns
+// the links whose target file or #fragment anchor does not exist, ordered
+// by page. Relative links are resolved against the output tree; absolute
+// links count as internal when they start with the site's baseurl.
+// Links to other sites are not checked.
+func CheckLinks(outputDir string, site config.SiteConfig) ([]BrokenLink, error) {
+	if _, err := os.Stat(outputDir); err != nil {
+		return nil, fmt.Errorf("no generated site in %s: %w", outputDir, err)
+	}
+	var files []string
+	err := filepath.Walk(outputDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.EqualFold(filepath.Ext(p), ".html") {
+			rel, err := filepath.Rel(outputDir, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	parsed := make([]*linkedPage, len(files))
+	if err := forEachParallel(len(files), func(i int) error {
+		page, err := parseLinkedPage(filepath.Join(outputDir, filepath.FromSlash(files[i])))
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", files[i], err)
+		}
+		parsed[i] = page
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	pages := make(map[string]*linkedPage, len(files))
+	for i, rel := range files {
+		pages[rel] = parsed[i]
+	}
+
+	checker := newLinkChecker(outputDir, pages, site.BaseURL)
+	var broken []BrokenLink
+	for _, rel := range files {
+		seen := make(map[string]bool)
+		for _, link := range pages[rel].links {
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			if reason := checker.check(rel, link); reason != "" {
+				broken = append(broken, BrokenLink{Page: rel, Link: link, Reason: reason})
+			}
+		}
+	}
+	return broken, nil
+}
+
+// parseLinkedPage collects the anchors and links of an HTML file.
+func parseLinkedPage(file string) (*linkedPage, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	page := &linkedPage{ids: make(map[string]bool)}
+	z := html.NewTokenizer(f)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return page, nil
+			}
+			return nil, z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			attrs := linkAttrs[token.Data]
+			for _, attr := range token.Attr {
+				switch {
+				case attr.Key == "id", attr.Key == "name" && token.Data == "a":
+					page.ids[attr.Val] = true
+				case attr.Key == "srcset" && containsString(attrs, "srcset"):
+					for _, candidate := range strings.Split(attr.Val, ",") {
+						if fields := strings.Fields(candidate); len(fields) > 0 {
+							page.links = append(page.links, fields[0])
+						}
+					}
+				case containsString(attrs, attr.Key):
+					if link := strings.TrimSpace(attr.Val); link != "" {
+						page.links = append(page.links, link)
+					}
+				}
+			}
+		}
+	}
+}
+
+type linkChecker struct {
+	outputDir string
+	pages     map[string]*linkedPage
+	baseURL   string // e.g. "https://example.com/stories/", or empty
+	basePath  string // e.g. "/stories/", or "/"
+}
+
+func newLinkChecker(outputDir string, pages map[string]*linkedPage, baseURL string) linkChecker {
+	c := linkChecker{outputDir: outputDir, pages: pages, basePath: sitePath(baseURL)}
+	if util.IsAbsoluteURL(baseURL) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/") + "/"
+	}
+	return c
+}
+
+// check returns why a link from page is broken, or "" if it resolves.
+func (c linkChecker) check(page, link string) string {
+	if c.baseURL != "" && strings.HasPrefix(link, c.baseURL) {
+		link = c.basePath + strings.TrimPrefix(link, c.baseURL)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "malformed link"
+	}
+	if u.Scheme != "" || u.Host != "" {
+		return "" // Another site, or mailto:, data: and the like
+	}
+
+	target := page
+	switch {
+	case u.Path == "":
+		// A fragment or query alone stays on the same page.
+	case u.Path+"/" == c.basePath:
+		target = "."
+	case strings.HasPrefix(u.Path, c.basePath):
+		target = path.Clean(strings.TrimPrefix(u.Path, c.basePath))
+	case strings.HasPrefix(u.Path, "/"):
+		return "outside the site's baseurl"
+	default:
+		target = path.Join(path.Dir(page), u.Path)
+	}
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "points outside the site"
+	}
+	info, err := os.Stat(filepath.Join(c.outputDir, filepath.FromSlash(target)))
+	if err != nil {
+		return "no such file"
+	}
+	if info.IsDir() {
+		target = path.Join(target, "index.html")
+		if _, err := os.Stat(filepath.Join(c.outputDir, filepath.FromSlash(target))); err != nil {
+			return "directory has no index.html"
+		}
+	}
+
+	// "#" and "#top" always scroll to the top of the page.
+	if u.Fragment == "" || u.Fragment == "top" {
+		return ""
+	}
+	if linked, ok := c.pages[target]; ok && !linked.ids[u.Fragment] {
+		return fmt.Sprintf("no anchor #%s in %s", u.Fragment, target)
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+// internal/builder/linkcheck_test.go
+package builder
+
+import (
+	"nibl/internal/config"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":          `<a href="book/">Book</a><a href="about.html#team">Team</a>`,
+		"about.html":          `<h2 id="team">Team</h2><a name="old"></a>`,
+		"book/index.html":     `<a href="ch1.html">One</a>`,
+		"book/ch1.html":       `<p id="top-of-text"></p>`,
+		"css/site.css":        `body {}`,
+		"images/a.jpg":        ``,
+		"images/a-480.jpg":    ``,
+		"empty/placeholder":   ``,
+		"book/ch2/index.html": `<a href="../ch1.html#top-of-text">Back</a>`,
+	}
+	for rel, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		page string
+		link string
+		want string // Empty when the link resolves
+	}{
+		{name: "relative file", page: "book/index.html", link: "ch1.html"},
+		{name: "parent directory", page: "book/ch2/index.html", link: "../ch1.html"},
+		{name: "directory with index", page: "index.html", link: "book/"},
+		{name: "directory without index", page: "index.html", link: "empty/", want: "directory has no index.html"},
+		{name: "missing file", page: "index.html", link: "nope.html", want: "no such file"},
+		{name: "anchor", page: "index.html", link: "about.html#team"},
+		{name: "named anchor", page: "index.html", link: "about.html#old"},
+		{name: "missing anchor", page: "index.html", link: "about.html#board", want: "no anchor #board in about.html"},
+		{name: "same page anchor", page: "about.html", link: "#team"},
+		{name: "top of page", page: "about.html", link: "#top"},
+		{name: "root-absolute", page: "book/ch1.html", link: "/stories/css/site.css"},
+		{name: "site root", page: "book/ch1.html", link: "/stories/"},
+		{name: "outside baseurl", page: "index.html", link: "/other/x.html", want: "outside the site's baseurl"},
+		{name: "above the output dir", page: "index.html", link: "../secret.html", want: "points outside the site"},
+		{name: "absolute internal", page: "index.html", link: "https://example.com/stories/book/ch1.html#top-of-text"},
+		{name: "absolute internal missing", page: "index.html", link: "https://example.com/stories/gone.html", want: "no such file"},
+		{name: "other site", page: "index.html", link: "https://elsewhere.org/gone.html"},
+		{name: "mailto", page: "index.html", link: "mailto:me@example.com"},
+	}
+	checker := newLinkChecker(dir, map[string]*linkedPage{
+		"about.html":    {ids: map[string]bool{"team": true, "old": true}},
+		"book/ch1.html": {ids: map[string]bool{"top-of-text": true}},
+	}, "https://example.com/stories/")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checker.check(tt.page, tt.link); got != tt.want {
+				t.Errorf("check(%q, %q) = %q, want %q", tt.page, tt.link, got, tt.want)
+			}
+		})
+	}
+
+	// A site at the domain root, as scaffolded or with no baseurl at all.
+	rootTests := []struct {
+		name string
+		page string
+		link string
+		want string
+	}{
+		{name: "same page anchor", page: "about.html", link: "#team"},
+		{name: "same page anchor in subdirectory", page: "book/ch1.html", link: "#top-of-text"},
+		{name: "missing same page anchor", page: "book/ch1.html", link: "#team", want: "no anchor #team in book/ch1.html"},
+		{name: "query and anchor", page: "about.html", link: "?lang=en#old"},
+		{name: "root-absolute", page: "book/ch1.html", link: "/about.html#team"},
+		{name: "site root", page: "book/ch1.html", link: "/"},
+		{name: "relative file", page: "book/index.html", link: "ch1.html"},
+	}
+	for _, baseURL := range []string{"/", ""} {
+		checker := newLinkChecker(dir, map[string]*linkedPage{
+			"about.html":    {ids: map[string]bool{"team": true, "old": true}},
+			"book/ch1.html": {ids: map[string]bool{"top-of-text": true}},
+		}, baseURL)
+		for _, tt := range rootTests {
+			t.Run(tt.name+" at baseurl "+strconv.Quote(baseURL), func(t *testing.T) {
+				if got := checker.check(tt.page, tt.link); got != tt.want {
+					t.Errorf("check(%q, %q) = %q, want %q", tt.page, tt.link, got, tt.want)
+				}
+			})
+		}
+	}
+
+	// The whole run parses every page, srcset candidates included.
+	if err := os.WriteFile(filepath.Join(dir, "gallery.html"), []byte(`<img src="images/a.jpg" srcset="images/a-480.jpg 480w, images/a-800.jpg 800w">`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	broken, err := CheckLinks(dir, config.SiteConfig{BaseURL: "https://example.com/stories/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, link := range broken {
+		got = append(got, link.String())
+	}
+	if want := "gallery.html: images/a-800.jpg (no such file)"; strings.Join(got, "\n") != want {
+		t.Errorf("CheckLinks found\n%s\nwant\n%s", strings.Join(got, "\n"), want)
+	}
+}
@@ -0,0 +1,211 @@
+// internal/builder/manifest.go
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"nibl/internal/config"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestFile is the name of the build manifest written to the output directory.
+// It lets the next build skip pages whose inputs have not changed.
+const manifestFile = ".nibl-manifest.json"
+
+// manifest records the hashes of every input that went into the last build.
+type manifest struct {
+	Config     string                   `json:"config"`
+	Templates  map[string]string        `json:"templates"`
+	Unsafe     bool                     `json:"unsafe,omitempty"`      // Built with --unsafe
+	Assets     string                   `json:"assets,omitempty"`      // Fingerprint of the asset pipeline's output
+	Collection string                   `json:"collection"`            // Fingerprint of what listings show of every page
+	Pages      map[string]manifestEntry `json:"pages"`                 // Keyed by path relative to the content dir
+	Generated  []string                 `json:"generated"`             // Outputs without a source, e.g. list pages
+	Static     []string                 `json:"static"`                // Files copied from the static dir
+	Images     []string                 `json:"images,omitempty"`      // Image variants written by templates
+	ImageFiles map[string]string        `json:"image_files,omitempty"` // Hashes of the static images they came from
+}
+
+// manifestEntry records a single source file and the output it produced.
+// Output is empty for sources that were skipped (e.g. drafts).
+type manifestEntry struct {
+	Hash   string `json:"hash"`
+	Output string `json:"output,omitempty"` // Slash-separated, relative to the output dir
+
+	// What listings show of the page, kept so an unchanged page need not be
+	// rendered again to list it. Summaries written with <!--more--> are not
+	// kept, as their links depend on other pages.
+	Words     int    `json:"words,omitempty"`
+	Summary   string `json:"summary,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// newManifestEntry records a published page.
+func newManifestEntry(page *Page) manifestEntry {
+	entry := manifestEntry{Hash: page.hash, Output: page.outputPath}
+	if !page.manualSummary {
+		entry.Words = page.WordCount
+		entry.Summary = string(page.Summary)
+		entry.Truncated = page.Truncated
+	}
+	return entry
+}
+
+// restoreContent gives an unchanged page the summary and word count of the
+// previous build. It reports false when they have to be worked out anew.
+func (e manifestEntry) restoreContent(page *Page) bool {
+	if e.Hash != page.hash || e.Words == 0 {
+		return false
+	}
+	page.WordCount = e.Words
+	page.ReadingTime = readingTime(e.Words)
+	page.Summary = template.HTML(e.Summary)
+	page.Truncated = e.Truncated
+	return true
+}
+
+// newManifest hashes the site configuration and every file of the active theme.
+func newManifest(site config.SiteConfig, opts BuildOptions) (*manifest, error) {
+	m := &manifest{
+		Unsafe:    opts.Unsafe,
+		Templates: make(map[string]string),
+		Pages:     make(map[string]manifestEntry),
+	}
+
+	// Prefer the raw config file so comments and ordering count as changes too;
+	// fall back to the parsed config when no file path was supplied.
+	if opts.ConfigFile != "" {
+		h, err := hashFile(opts.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash config file: %w", err)
+		}
+		m.Config = h
+	} else {
+		data, err := yaml.Marshal(site)
+		if err != nil {
+			return nil, err
+		}
+		m.Config = hashBytes(data)
+	}
+
+	if opts.TemplateDir == "" {
+		return m, nil
+	}
+	themeDir := filepath.Join(opts.TemplateDir, site.Template)
+	err := filepath.Walk(themeDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(themeDir, path)
+		if err != nil {
+			return err
+		}
+		h, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		m.Templates[filepath.ToSlash(rel)] = h
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash templates: %w", err)
+	}
+	return m, nil
+}
+
+// loadManifest reads the manifest left by a previous build.
+// A missing or unreadable manifest yields nil, which forces a full rebuild.
+func loadManifest(outputDir string) *manifest {
+	data, err := os.ReadFile(filepath.Join(outputDir, manifestFile))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Warning: could not read build manifest, rebuilding everything: %v\n", err)
+		}
+		return nil
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		fmt.Printf("Warning: build manifest is corrupt, rebuilding everything: %v\n", err)
+		return nil
+	}
+	return &m
+}
+
+// save writes the manifest into the output directory.
+func (m *manifest) save(outputDir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputDir, manifestFile), data, 0644)
+}
+
+// sharedInputsMatch reports whether the config and templates are unchanged,
+// which is the precondition for reusing any previously rendered page.
+func (m *manifest) sharedInputsMatch(other *manifest) bool {
+	if other == nil || m.Config != other.Config || m.Unsafe != other.Unsafe || m.Assets != other.Assets || len(m.Templates) != len(other.Templates) {
+		return false
+	}
+	for name, h := range m.Templates {
+		if other.Templates[name] != h {
+			return false
+		}
+	}
+	return true
+}
+
+// imagesUnchanged reports whether every image processed by the last build
+// still has the same content. Pages link variants by a name derived from
+// it, so an edited image means those pages must be rendered again.
+func (m *manifest) imagesUnchanged(staticDir string) bool {
+	for rel, h := range m.ImageFiles {
+		current, err := hashFile(filepath.Join(staticDir, filepath.FromSlash(rel)))
+		if err != nil || current != h {
+			return false
+		}
+	}
+	return true
+}
+
+// outputs returns the set of every file the build wrote.
+func (m *manifest) outputs() map[string]bool {
+	set := make(map[string]bool, len(m.Pages)+len(m.Generated)+len(m.Static)+len(m.Images))
+	for _, entry := range m.Pages {
+		if entry.Output != "" {
+			set[entry.Output] = true
+		}
+	}
+	for _, output := range m.Generated {
+		set[output] = true
+	}
+	for _, output := range m.Static {
+		set[output] = true
+	}
+	for _, output := range m.Images {
+		set[output] = true
+	}
+	return set
+}
+
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return hashBytes(data), nil
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,283 @@
+// internal/builder/minify.go
+package builder
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// The minifiers below are deliberately conservative: they only drop
+// comments and whitespace that cannot change how a browser reads the file.
+
+// minifyCSS removes comments (except /*! ... */ licence comments) and
+// collapses whitespace, dropping it entirely around { } ; , > and after :.
+func minifyCSS(src []byte) []byte {
+	var out bytes.Buffer
+	space := false // A run of whitespace is pending
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := quotedEnd(src, i)
+			flushSpace(&out, &space, c)
+			out.Write(src[i:end])
+			i = end - 1
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 4
+			}
+			if i+2 < len(src) && src[i+2] == '!' {
+				flushSpace(&out, &space, c)
+				out.Write(src[i:end])
+			} else {
+				space = true
+			}
+			i = end - 1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+		default:
+			if c == '}' && bytes.HasSuffix(out.Bytes(), []byte(";")) {
+				out.Truncate(out.Len() - 1)
+			}
+			flushSpace(&out, &space, c)
+			out.WriteByte(c)
+		}
+	}
+	return bytes.TrimSpace(out.Bytes())
+}
+
+// flushSpace writes a pending space before c unless CSS punctuation on
+// either side makes it redundant.
+func flushSpace(out *bytes.Buffer, space *bool, c byte) {
+	if !*space {
+		return
+	}
+	*space = false
+	if out.Len() == 0 || strings.IndexByte("{};,>", c) >= 0 {
+		return
+	}
+	if last := out.Bytes()[out.Len()-1]; strings.IndexByte("{};,>:", last) >= 0 {
+		return
+	}
+	out.WriteByte(' ')
+}
+
+// quotedEnd returns the index just past the string literal starting at i.
+func quotedEnd(src []byte, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// minifyJS removes comments, indentation and blank lines but keeps every
+// line break, so automatic semicolon insertion works exactly as before.
+// Whitespace is only touched outside of strings, template literals and
+// regular expression literals, which are copied untouched.
+func minifyJS(src []byte) []byte {
+	var out bytes.Buffer
+	space := false // A run of spaces or tabs is pending
+	// emit writes a token, preceded by a single space for any whitespace
+	// before it on the same line.
+	emit := func(token []byte) {
+		if space && out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+			out.WriteByte(' ')
+		}
+		space = false
+		out.Write(token)
+	}
+	newline := func() {
+		space = false
+		if out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+			out.WriteByte('\n')
+		}
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := quotedEnd(src, i)
+			emit(src[i:end])
+			i = end - 1
+		case c == '`':
+			end := templateEnd(src, i)
+			emit(src[i:end])
+			i = end - 1
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(src)
+				break
+			}
+			if bytes.Contains(src[i:i+end+4], []byte("\n")) {
+				newline()
+			} else {
+				space = true
+			}
+			i += end + 3
+		case c == '/' && regexAllowed(out.Bytes()):
+			end := regexEnd(src, i)
+			emit(src[i:end])
+			i = end - 1
+		case c == '\n':
+			newline()
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			space = true
+		default:
+			emit(src[i : i+1])
+		}
+	}
+	return bytes.TrimRight(out.Bytes(), "\n")
+}
+
+// templateEnd returns the index just past the template literal starting at
+// i, skipping over any ${...} substitutions, which may hold strings and
+// template literals of their own.
+func templateEnd(src []byte, i int) int {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '`':
+			return j + 1
+		case '$':
+			if j+1 >= len(src) || src[j+1] != '{' {
+				continue
+			}
+			depth := 0
+		substitution:
+			for j++; j < len(src); j++ {
+				switch src[j] {
+				case '{':
+					depth++
+				case '}':
+					if depth--; depth == 0 {
+						break substitution
+					}
+				case '"', '\'':
+					j = quotedEnd(src, j) - 1
+				case '`':
+					j = templateEnd(src, j) - 1
+				}
+			}
+		}
+	}
+	return len(src)
+}
+
+// regexAllowed reports whether a / after the given output starts a regular
+// expression literal rather than a division.
+func regexAllowed(before []byte) bool {
+	trimmed := bytes.TrimRight(before, " \t\r\n")
+	if len(trimmed) == 0 {
+		return true
+	}
+	if strings.IndexByte("(,=:[!&|?{};+-*%<>~^", trimmed[len(trimmed)-1]) >= 0 {
+		return true
+	}
+	for _, keyword := range []string{"return", "typeof", "case", "do", "else", "in", "of", "new", "delete", "void", "throw"} {
+		if bytes.HasSuffix(trimmed, []byte(keyword)) {
+			rest := trimmed[:len(trimmed)-len(keyword)]
+			if len(rest) == 0 || !isIdentByte(rest[len(rest)-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// regexEnd returns the index just past the regular expression literal
+// (including its flags) starting at i.
+func regexEnd(src []byte, i int) int {
+	inClass := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return j // Not a regex after all; leave the rest alone
+		case '/':
+			if !inClass {
+				for j++; j < len(src) && isIdentByte(src[j]); j++ {
+				}
+				return j
+			}
+		}
+	}
+	return len(src)
+}
+
+var (
+	htmlComment   = regexp.MustCompile(`(?s)<!--(?:[^\[].*?)?-->`)
+	htmlRawBlock  = regexp.MustCompile(`(?is)<(pre|textarea|script|style)\b.*?</(?:pre|textarea|script|style)>`)
+	htmlSpaceRun  = regexp.MustCompile(`[ \t\r\n]+`)
+	htmlBlankLine = regexp.MustCompile(`\n[ \t]*\n`)
+)
+
+// minifyHTML removes comments (but not <!--[if ...]> conditionals) and
+// collapses whitespace to a single space or line break. The contents of
+// <pre>, <textarea>, <script> and <style> are left as they are, apart from
+// inline styles and scripts going through the CSS and JS minifiers.
+func minifyHTML(src []byte) []byte {
+	var out bytes.Buffer
+	last := 0
+	for _, loc := range htmlRawBlock.FindAllSubmatchIndex(src, -1) {
+		out.Write(collapseHTML(src[last:loc[0]]))
+		block := src[loc[0]:loc[1]]
+		switch tag := strings.ToLower(string(src[loc[2]:loc[3]])); tag {
+		case "style", "script":
+			open := bytes.IndexByte(block, '>') + 1
+			end := bytes.LastIndex(block, []byte("</"))
+			body := block[open:end]
+			if tag == "style" {
+				body = minifyCSS(body)
+			} else if !bytes.Contains(block[:open], []byte("type=")) || bytes.Contains(block[:open], []byte("javascript")) || bytes.Contains(block[:open], []byte("module")) {
+				body = minifyJS(body)
+			}
+			out.Write(block[:open])
+			out.Write(body)
+			out.Write(block[end:])
+		default:
+			out.Write(block)
+		}
+		last = loc[1]
+	}
+	out.Write(collapseHTML(src[last:]))
+	return bytes.TrimSpace(out.Bytes())
+}
+
+// collapseHTML minifies HTML outside of raw blocks.
+func collapseHTML(src []byte) []byte {
+	src = htmlComment.ReplaceAll(src, nil)
+	src = htmlBlankLine.ReplaceAll(src, []byte("\n"))
+	return htmlSpaceRun.ReplaceAllFunc(src, func(run []byte) []byte {
+		if bytes.IndexByte(run, '\n') >= 0 {
+			return []byte("\n")
+		}
+		return []byte(" ")
+	})
+}
@@ -0,0 +1,101 @@
+// internal/builder/minify_test.go
+package builder
+
+import "testing"
+
+func TestMinifyJS(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "indentation and blank lines",
+			src:  "function f() {\n    return 1;\n\n}\n",
+			want: "function f() {\nreturn 1;\n}",
+		},
+		{
+			name: "template literal keeps its lines",
+			src:  "const s = `\n    line one\n      line two\n`;\n",
+			want: "const s = `\n    line one\n      line two\n`;",
+		},
+		{
+			name: "nested template literal",
+			src:  "el.innerHTML = `<ul>\n  ${items.map(i => `\n    <li>${i}</li>`).join(\"\")}\n</ul>`;\n",
+			want: "el.innerHTML = `<ul>\n  ${items.map(i => `\n    <li>${i}</li>`).join(\"\")}\n</ul>`;",
+		},
+		{
+			name: "comment markers inside strings",
+			src:  "var url = \"http://example.com\"; // a comment\nvar b = '/* not a comment */';\n",
+			want: "var url = \"http://example.com\";\nvar b = '/* not a comment */';",
+		},
+		{
+			name: "regex literal with slashes",
+			src:  "var re = /\\/\\/ +x/g;  // strip\n",
+			want: "var re = /\\/\\/ +x/g;",
+		},
+		{
+			name: "line breaks kept for semicolon insertion",
+			src:  "let a = b\n  ++c\nreturn\n  a\n",
+			want: "let a = b\n++c\nreturn\na",
+		},
+		{
+			name: "block comments",
+			src:  "a = 1 /* one */ + 2\n/*\n multi\n*/\nb = 3\n",
+			want: "a = 1 + 2\nb = 3",
+		},
+		{
+			name: "division is not a regex",
+			src:  "x = a / b / c\n",
+			want: "x = a / b / c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(minifyJS([]byte(tt.src))); got != tt.want {
+				t.Errorf("minifyJS(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "whitespace and comments", src: "a {\n  color: red;\n  /* note */\n  margin: 0 auto;\n}\n", want: "a{color:red;margin:0 auto}"},
+		{name: "licence comment kept", src: "/*! MIT */\nb { x: y }", want: "/*! MIT */ b{x:y}"},
+		{name: "strings untouched", src: "a::after { content: \"  {  ;  }  \"; }", want: "a::after{content:\"  {  ;  }  \"}"},
+		{name: "selectors", src: "ul > li ,  ol  li { }", want: "ul>li,ol li{}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(minifyCSS([]byte(tt.src))); got != tt.want {
+				t.Errorf("minifyCSS(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "comments and spaces", src: "<p>  a   <!-- x -->b </p>\n\n\n<p>c</p>", want: "<p> a b </p>\n<p>c</p>"},
+		{name: "conditional comment kept", src: "<!--[if IE]><p>x</p><![endif]-->", want: "<!--[if IE]><p>x</p><![endif]-->"},
+		{name: "pre untouched", src: "<pre>  a\n\n  b</pre>", want: "<pre>  a\n\n  b</pre>"},
+		{name: "inline script minified", src: "<script>\n  var a = `\n  x`;\n</script>", want: "<script>var a = `\n  x`;</script>"},
+		{name: "json script untouched", src: "<script type=\"application/json\">{ \"a\":  1 }</script>", want: "<script type=\"application/json\">{ \"a\":  1 }</script>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(minifyHTML([]byte(tt.src))); got != tt.want {
+				t.Errorf("minifyHTML(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
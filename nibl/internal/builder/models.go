@@ -4,32 +4,124 @@ package builder
 import (
 	"html/template"
 	"nibl/internal/config"
+	"time"
+
+	"github.com/yuin/goldmark"
 )
 
 // PageMeta holds metadata from front matter. It now includes a map
 // for arbitrary parameters defined in the source markdown or biff file.
 type PageMeta struct {
-	Title       string                 `yaml:"title"`
-	Author      string                 `yaml:"author"` // Per-page author (fallback)
-	Draft       bool                   `yaml:"draft"`
-	Description string                 `yaml:"description"`
-	ShowEditML  bool                   `yaml:"showEditML"`
-	StoryTitle  string                 `yaml:"story_title"`  // Global story title from biff
-	StoryAuthor string                 `yaml:"story_author"` // Global story author from biff
-	Params      map[string]interface{} `yaml:",inline"`
+	Title          string                 `yaml:"title"`
+	Author         string                 `yaml:"author"` // Per-page author (fallback)
+	Draft          bool                   `yaml:"draft"`
+	Description    string                 `yaml:"description"`
+	ShowEditML     bool                   `yaml:"showEditML"`
+	StoryTitle     string                 `yaml:"story_title"`    // Global story title from biff
+	StoryAuthor    string                 `yaml:"story_author"`   // Global story author from biff
+	Weight         int                    `yaml:"weight"`         // Sort order within collections
+	Slug           string                 `yaml:"slug"`           // Replaces the file name in the page's URL
+	CustomURL      string                 `yaml:"url"`            // Overrides the page's URL entirely
+	Aliases        []string               `yaml:"aliases"`        // Old URLs that redirect to the page
+	Layout         string                 `yaml:"layout"`         // Layout name tried before the defaults, e.g. "ending"
+	Type           string                 `yaml:"type"`           // Layout directory searched before the section's
+	Date           Date                   `yaml:"date"`           // Falls back to publishDate
+	PublishDate    Date                   `yaml:"publishDate"`    // Page is skipped before this date unless --future
+	ExpiryDate     Date                   `yaml:"expiryDate"`     // Page is skipped from this date unless --expired
+	Lastmod        Date                   `yaml:"lastmod"`        // Falls back to date
+	Sitemap        *bool                  `yaml:"sitemap"`        // Set to false to leave the page out of sitemap.xml
+	Variant        bool                   `yaml:"state_variant"`  // Set by the story compiler on state-variant knots
+	Trust          string                 `yaml:"trust"`          // How much HTML to keep: untrusted, default or trusted
+	TranslationKey string                 `yaml:"translationKey"` // Links translations whose paths differ
+	TocDepth       int                    `yaml:"tocDepth"`       // Heading levels in .TableOfContents, 3 if unset
+	Params         map[string]interface{} `yaml:",inline"`
 }
 
 // PageData is the struct passed to templates. It now includes the
 // arbitrary parameters, making them available in templates via `.Params`.
 type PageData struct {
-	Content     template.HTML
-	Title       string // The title of the specific page/knot
-	BaseHref    string
-	Author      string // The final author to be displayed
-	Description string
-	Site        config.SiteConfig
-	ShowEditML  bool
-	StoryTitle  string // The global title of the story
-	Params      map[string]interface{}
+	Content         template.HTML
+	Title           string // The title of the specific page/knot
+	BaseHref        string
+	Author          string // The final author to be displayed
+	Description     string
+	Site            SiteData
+	ShowEditML      bool
+	StoryTitle      string // The global title of the story
+	Params          map[string]interface{}
+	Date            Date
+	PublishDate     Date
+	ExpiryDate      Date
+	Lastmod         Date
+	Draft           bool          // Only ever true when building with --drafts
+	Translations    Pages         // The same page in the site's other languages
+	Prev, Next      *Page         // Neighbouring pages in the section, nil at either end
+	Summary         template.HTML // The text before <!--more-->, or the first words of the page
+	Truncated       bool          // True when Content goes on beyond the Summary
+	WordCount       int
+	ReadingTime     int           // Estimated minutes to read the page
+	TableOfContents template.HTML // Nested links to the page's headings, empty if it has none
+	Headings        []*Heading    // The same headings as a tree, for custom navigation
+	Pages           Pages         // Child pages, set on section list pages and index pages
+	Terms           Terms         // Set on taxonomy term list pages
+	Term            *Term         // Set on the page of a single taxonomy term
 }
 
+// SiteData is passed to templates as `.Site`. It embeds the configuration,
+// so `.Site.Title` keeps working, and adds every published page.
+type SiteData struct {
+	config.SiteConfig
+	Pages Pages // All published content pages of the language, ordered by path
+
+	// Language is the language being built; Languages lists every language
+	// of the site, the default first. Both are empty on single-language sites.
+	Language  Language
+	Languages []Language
+
+	// HasFeed is true when the language's root has an index.xml feed, and
+	// HighlightCSS is the path of the stylesheet for highlighted code, ""
+	// when highlighting is disabled. Layouts check both before linking them.
+	HasFeed      bool
+	HighlightCSS string
+
+	// Taxonomies maps each configured taxonomy to its terms, e.g.
+	// `.Site.Taxonomies.characters`. It shadows the config's list of names.
+	Taxonomies map[string]Terms
+
+	markdown  goldmark.Markdown // Renders page bodies with the site's settings
+	sanitizer *sanitizer        // Cleans the rendered HTML
+}
+
+// Page describes a piece of content as seen from other pages, for example
+// in the .Pages listing of a section. The embedded PageMeta makes front
+// matter available directly, e.g. {{ .Title }} or {{ .Params.mood }}.
+type Page struct {
+	PageMeta
+	URL       string // Link relative to the site root, e.g. "chapter-1/scene.html" or "chapter-1/scene/"
+	Section   string // Directory relative to content/, "" for the root; starts with the language's Dir
+	IsSection bool   // True when the entry is the list page of a sub-directory
+	Language  Language
+
+	// Filled in once the page's content is rendered, so list pages can
+	// show a teaser of each entry.
+	Summary     template.HTML // The text before <!--more-->, or the first words of the page
+	Truncated   bool          // True when the page goes on beyond the Summary
+	WordCount   int
+	ReadingTime int // Estimated minutes to read the page
+
+	outputPath   string    // Slash-separated file path relative to the output dir
+	logical      string    // Slash-separated content path with the language moved to the front, e.g. "es/book/ch1.md"
+	translations Pages     // The same page in other languages
+	prev, next   *Page     // Neighbours in the section's reading order
+	modTime      time.Time // Modification time of the source file
+	sourcePath   string    // Path of the source file, empty for generated pages
+	relPath      string    // Source path relative to content/
+	hash         string    // Hash of the raw source file
+	body         []byte    // Markdown body without front matter
+
+	// Set by renderContent.
+	content       template.HTML
+	headings      []*Heading
+	rendered      bool
+	manualSummary bool // The summary comes from a <!--more--> divider
+}
@@ -0,0 +1,289 @@
+// internal/builder/pages.go
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// loadPages walks the content directory and reads the front matter of every
+// page. Markdown is not rendered here, so unchanged pages stay cheap.
+func loadPages(contentDir string) ([]*Page, error) {
+	var pages []*Page
+	if err := filepath.Walk(contentDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := filepath.Ext(info.Name())
+		if ext != ".html" && ext != ".md" {
+			return nil
+		}
+		relPath, err := filepath.Rel(contentDir, path)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, &Page{sourcePath: path, relPath: relPath, logical: filepath.ToSlash(relPath), modTime: info.ModTime()})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := forEachParallel(len(pages), func(i int) error {
+		return pages[i].load()
+	}); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// load reads the source file and fills in the page's metadata and paths.
+func (p *Page) load() error {
+	contentBytes, err := os.ReadFile(p.sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", p.sourcePath, err)
+	}
+	if !utf8.Valid(contentBytes) {
+		return fmt.Errorf("content file is not valid UTF-8: %s", p.sourcePath)
+	}
+	p.hash = hashBytes(contentBytes)
+
+	meta, body, err := splitFrontMatter(p.sourcePath, contentBytes)
+	if err != nil {
+		return err
+	}
+	meta.applyDateDefaults()
+	p.PageMeta = meta
+	p.body = body
+
+	p.Section = sectionOf(p.logical)
+	return nil
+}
+
+// slug returns the page's content path without its extension. On
+// multilingual sites it starts with the language's directory, e.g. "es/ch1"
+// for both content/es/ch1.md and content/ch1.es.md.
+func (p *Page) slug() string {
+	return strings.TrimSuffix(p.logical, path.Ext(p.logical))
+}
+
+// isIndex reports whether the page is the hand-written index of its directory.
+func (p *Page) isIndex() bool {
+	return path.Base(p.slug()) == "index"
+}
+
+// sectionOf returns the directory part of a slash-separated path, "" for the root.
+func sectionOf(rel string) string {
+	dir := path.Dir(rel)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// section is a directory of the content tree together with its children.
+type section struct {
+	path  string   // Relative to content/, "" for the root
+	index *Page    // Hand-written index page, if any
+	pages []*Page  // Child pages followed by sub-section entries
+	subs  []string // Child directories, relative to content/
+}
+
+// buildSections groups published pages by directory. Every ancestor of a page
+// up to root becomes a section too, so a book directory that only holds
+// chapter directories still lists them. The root is "" except for the
+// pages of a language other than the default, which stop at its directory.
+func buildSections(pages []*Page, pretty bool, root string) map[string]*section {
+	sections := make(map[string]*section)
+	get := func(dir string) *section {
+		sec, ok := sections[dir]
+		if !ok {
+			sec = &section{path: dir}
+			sections[dir] = sec
+		}
+		return sec
+	}
+
+	for _, page := range pages {
+		sec := get(page.Section)
+		if page.isIndex() {
+			sec.index = page
+		} else {
+			sec.pages = append(sec.pages, page)
+		}
+		// Register the directory chain up to the root.
+		for dir := page.Section; dir != root && dir != ""; dir = sectionOf(dir) {
+			get(dir)
+			parent := get(sectionOf(dir))
+			if !containsString(parent.subs, dir) {
+				parent.subs = append(parent.subs, dir)
+			}
+		}
+	}
+
+	for _, sec := range sections {
+		sort.Strings(sec.subs)
+		for _, sub := range sec.subs {
+			sec.pages = append(sec.pages, sections[sub].listPage(pretty))
+		}
+	}
+	return sections
+}
+
+// linkSiblings connects the pages of every section in the order of
+// Pages.ByOrder, for .Prev and .Next. Story knots and their state variants
+// are left out, as a branching story is not read from one file to the next.
+func linkSiblings(sections map[string]*section) {
+	for _, sec := range sections {
+		var sequence Pages
+		for _, page := range sec.pages {
+			if !page.IsSection && !page.Variant && page.StoryTitle == "" {
+				sequence = append(sequence, page)
+			}
+		}
+		sequence = sequence.ByOrder()
+		for i, page := range sequence {
+			if i > 0 {
+				page.prev = sequence[i-1]
+			}
+			if i < len(sequence)-1 {
+				page.next = sequence[i+1]
+			}
+		}
+	}
+}
+
+// Prev returns the page before this one in its section, or nil.
+func (p *Page) Prev() *Page {
+	return p.prev
+}
+
+// Next returns the page after this one in its section, or nil.
+func (p *Page) Next() *Page {
+	return p.next
+}
+
+// sortedSections returns the sections ordered by path, for stable output.
+func sortedSections(sections map[string]*section) []*section {
+	sorted := make([]*section, 0, len(sections))
+	for _, sec := range sections {
+		sorted = append(sorted, sec)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].path < sorted[j].path })
+	return sorted
+}
+
+// listOutput is the output path of the section's generated list page.
+func (s *section) listOutput() string {
+	if s.path == "" {
+		return "index.html"
+	}
+	return s.path + "/index.html"
+}
+
+// listPage describes the section as an entry in its parent's listing. The
+// title and location come from a hand-written index when there is one.
+func (s *section) listPage(pretty bool) *Page {
+	page := &Page{
+		URL:        linkFor(s.listOutput(), pretty),
+		Section:    sectionOf(s.path),
+		IsSection:  true,
+		outputPath: s.listOutput(),
+	}
+	if s.index != nil {
+		page.PageMeta = s.index.PageMeta
+		page.URL = s.index.URL
+		page.outputPath = s.index.outputPath
+	}
+	if page.Title == "" {
+		page.Title = titleCase(strings.NewReplacer("-", " ", "_", " ").Replace(path.Base(s.path)))
+	}
+	return page
+}
+
+// titleCase capitalizes the words of a directory or taxonomy name, e.g.
+// "dramatis personae" to "Dramatis Personae". A new caser is made every
+// time, since casers keep state and pages are rendered in parallel.
+func titleCase(s string) string {
+	return cases.Title(language.Und, cases.NoLower).String(s)
+}
+
+// collectionHash fingerprints what listings show of every published page:
+// its location, title, dates, summary, word count and the like. It changes
+// whenever a listing of those pages could change, but not when an edit to a
+// page's body leaves all of these as they were.
+func collectionHash(pages []*Page) string {
+	type entry struct {
+		URL            string
+		Title          string
+		Description    string
+		Author         string
+		StoryTitle     string
+		StoryAuthor    string
+		Date           Date
+		Lastmod        Date
+		Weight         int
+		Draft          bool
+		Variant        bool
+		Params         map[string]interface{}
+		TranslationKey string
+		Language       Language
+		Summary        template.HTML
+		Truncated      bool
+		Words          int // ReadingTime follows from it
+	}
+	entries := make([]entry, len(pages))
+	for i, page := range pages {
+		entries[i] = entry{
+			URL:            page.URL,
+			Title:          page.Title,
+			Description:    page.Description,
+			Author:         page.Author,
+			StoryTitle:     page.StoryTitle,
+			StoryAuthor:    page.StoryAuthor,
+			Date:           page.Date,
+			Lastmod:        page.Lastmod,
+			Weight:         page.Weight,
+			Draft:          page.Draft,
+			Variant:        page.Variant,
+			Params:         page.Params,
+			TranslationKey: page.TranslationKey,
+			Language:       page.Language,
+			Summary:        page.Summary,
+			Truncated:      page.Truncated,
+			Words:          page.WordCount,
+		}
+	}
+	return hashJSON(entries)
+}
+
+// hashJSON hashes the JSON encoding of v. Front matter that cannot be
+// marshalled simply disables reuse, since an empty hash never matches.
+func hashJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return hashBytes(data)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
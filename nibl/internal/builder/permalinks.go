@@ -0,0 +1,145 @@
+// internal/builder/permalinks.go
+package builder
+
+import (
+	"fmt"
+	"nibl/internal/config"
+	"nibl/internal/util"
+	"path"
+	"strings"
+)
+
+// resolveOutputs decides where every page is written and how it is linked,
+// and reports an error when two pages would be written to the same file.
+func resolveOutputs(pages []*Page, site config.SiteConfig) error {
+	owners := make(map[string]*Page, len(pages))
+	for _, page := range pages {
+		if err := page.resolveOutput(site); err != nil {
+			return fmt.Errorf("invalid URL for %s: %w", page.sourcePath, err)
+		}
+		if other, ok := owners[page.outputPath]; ok {
+			return fmt.Errorf("%s and %s both resolve to %s", other.sourcePath, page.sourcePath, page.outputPath)
+		}
+		owners[page.outputPath] = page
+	}
+	return nil
+}
+
+// claimOutputs maps the output of every published and generated page to a
+// description of what writes it, reporting an error when a generated list
+// or taxonomy page would replace a content page or another generated page,
+// e.g. content/book.md and the list page of content/book/ with pretty_urls.
+func claimOutputs(pages []*Page, generated []generatedPage) (map[string]string, error) {
+	taken := make(map[string]string, len(pages)+len(generated))
+	for _, page := range pages {
+		taken[page.outputPath] = page.sourcePath
+	}
+	for _, gen := range generated {
+		if owner, ok := taken[gen.url]; ok {
+			return nil, fmt.Errorf("the generated page %s would replace %s", gen.url, owner)
+		}
+		taken[gen.url] = "the generated page " + gen.url
+	}
+	return taken, nil
+}
+
+// resolveOutput sets the page's output path and URL. The `url:` front matter
+// key wins, then the permalink pattern of the page's section, then the
+// source path with `slug:` replacing the file name. Index pages stay the
+// index of their directory unless they set `url:`. URLs and patterns are
+// relative to the directory of the page's language.
+func (p *Page) resolveOutput(site config.SiteConfig) error {
+	var target string // Output path without extension or trailing index.html
+	switch {
+	case p.CustomURL != "":
+		custom := strings.TrimPrefix(path.Clean("/"+p.CustomURL), "/")
+		custom = strings.TrimSuffix(p.Language.Dir+custom, "/")
+		switch {
+		case strings.HasSuffix(p.CustomURL, "/") || custom == "":
+			p.outputPath = path.Join(custom, "index.html")
+		case path.Ext(custom) == ".html":
+			p.outputPath = custom
+		default:
+			target = custom
+		}
+	case p.isIndex():
+		p.outputPath = p.slug() + ".html"
+	default:
+		if pattern, ok := permalinkPattern(site.Permalinks, p.langSection()); ok {
+			expanded, err := p.expandPermalink(pattern)
+			if err != nil {
+				return err
+			}
+			target = p.Language.Dir + expanded
+		} else {
+			target = path.Join(p.Section, p.slugOrFilename())
+		}
+	}
+
+	if p.outputPath == "" {
+		if site.PrettyURLs {
+			p.outputPath = target + "/index.html"
+		} else {
+			p.outputPath = target + ".html"
+		}
+	}
+	p.URL = linkFor(p.outputPath, site.PrettyURLs)
+	return nil
+}
+
+// permalinkPattern finds the pattern configured for a section. A pattern for
+// "book" also covers "book/chapter-1" unless that has a pattern of its own.
+func permalinkPattern(patterns map[string]string, section string) (string, bool) {
+	for dir := section; ; dir = sectionOf(dir) {
+		for key, pattern := range patterns {
+			if strings.Trim(key, "/") == dir {
+				return pattern, true
+			}
+		}
+		if dir == "" {
+			return "", false
+		}
+	}
+}
+
+// expandPermalink replaces the placeholders of a permalink pattern:
+// :section, :slug, :filename, :title, :year, :month and :day. The date
+// placeholders are an error on a page without a date, rather than a year 1.
+func (p *Page) expandPermalink(pattern string) (string, error) {
+	date := p.Date
+	if date.IsZero() && (strings.Contains(pattern, ":year") || strings.Contains(pattern, ":month") || strings.Contains(pattern, ":day")) {
+		return "", fmt.Errorf("permalink pattern %q needs a date, but the page has none; set `date:` in its front matter", pattern)
+	}
+	replacer := strings.NewReplacer(
+		":section", p.langSection(),
+		":slug", p.slugOrFilename(),
+		":filename", path.Base(p.slug()),
+		":title", util.Slugify(p.Title),
+		":year", date.Format("2006"),
+		":month", date.Format("01"),
+		":day", date.Format("02"),
+	)
+	expanded := strings.Trim(path.Clean("/"+replacer.Replace(pattern)), "/")
+	if expanded == "" {
+		return "", fmt.Errorf("permalink pattern %q expands to an empty path", pattern)
+	}
+	return expanded, nil
+}
+
+// slugOrFilename returns the `slug:` front matter value, or the source file
+// name without its extension.
+func (p *Page) slugOrFilename() string {
+	if p.Slug != "" {
+		return util.Slugify(p.Slug)
+	}
+	return path.Base(p.slug())
+}
+
+// linkFor returns how an output file is linked from other pages. With pretty
+// URLs, index files are addressed by their directory, e.g. "chapter-1/".
+func linkFor(outputPath string, pretty bool) string {
+	if pretty && (outputPath == "index.html" || strings.HasSuffix(outputPath, "/index.html")) {
+		return strings.TrimSuffix(outputPath, "index.html")
+	}
+	return outputPath
+}
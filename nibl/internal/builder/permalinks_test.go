@@ -0,0 +1,174 @@
+// internal/builder/permalinks_test.go
+package builder
+
+import (
+	"nibl/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPage returns a page at the given content path, as loadPages would.
+func testPage(rel string, meta PageMeta) *Page {
+	return &Page{PageMeta: meta, logical: rel, relPath: rel, sourcePath: "content/" + rel, Section: sectionOf(rel)}
+}
+
+func TestResolveOutput(t *testing.T) {
+	date := Date{time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)}
+	patterns := map[string]string{
+		"book":  ":section/:year/:month/:day/:slug",
+		"/news": ":title",
+	}
+	tests := []struct {
+		name    string
+		rel     string
+		meta    PageMeta
+		pretty  bool
+		wantOut string
+		wantURL string
+	}{
+		{name: "plain", rel: "about.md", wantOut: "about.html", wantURL: "about.html"},
+		{name: "pretty", rel: "about.md", pretty: true, wantOut: "about/index.html", wantURL: "about/"},
+		{name: "root index", rel: "index.md", pretty: true, wantOut: "index.html", wantURL: ""},
+		{name: "section index ignores pattern", rel: "book/index.md", pretty: true, wantOut: "book/index.html", wantURL: "book/"},
+		{name: "slug", rel: "misc/page.md", meta: PageMeta{Slug: "Other Name"}, wantOut: "misc/other-name.html", wantURL: "misc/other-name.html"},
+		{name: "dated pattern", rel: "book/ch1.md", meta: PageMeta{Date: date}, wantOut: "book/2024/03/09/ch1.html", wantURL: "book/2024/03/09/ch1.html"},
+		{name: "pattern covers subsections", rel: "book/part/ch2.md", meta: PageMeta{Date: date, Slug: "two"}, pretty: true, wantOut: "book/part/2024/03/09/two/index.html", wantURL: "book/part/2024/03/09/two/"},
+		{name: "title pattern", rel: "news/item.md", meta: PageMeta{Title: "Big News!"}, wantOut: "big-news.html", wantURL: "big-news.html"},
+		{name: "custom url wins", rel: "book/ch3.md", meta: PageMeta{CustomURL: "/elsewhere/"}, wantOut: "elsewhere/index.html", wantURL: "elsewhere/index.html"},
+		{name: "custom url with extension", rel: "a.md", meta: PageMeta{CustomURL: "b/c.html"}, pretty: true, wantOut: "b/c.html", wantURL: "b/c.html"},
+		{name: "custom url follows pretty_urls", rel: "a.md", meta: PageMeta{CustomURL: "b/c"}, pretty: true, wantOut: "b/c/index.html", wantURL: "b/c/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := testPage(tt.rel, tt.meta)
+			if err := page.resolveOutput(config.SiteConfig{PrettyURLs: tt.pretty, Permalinks: patterns}); err != nil {
+				t.Fatal(err)
+			}
+			if page.outputPath != tt.wantOut || page.URL != tt.wantURL {
+				t.Errorf("got %q linked as %q, want %q linked as %q", page.outputPath, page.URL, tt.wantOut, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestExpandPermalinkErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: ":title", want: "expands to an empty path"},
+		{pattern: ":year/:slug", want: "needs a date"},
+		{pattern: "archive/:month-:day", want: "needs a date"},
+	}
+	for _, tt := range tests {
+		page := testPage("news/item.md", PageMeta{})
+		if _, err := page.expandPermalink(tt.pattern); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expandPermalink(%q) = %v, want an error containing %q", tt.pattern, err, tt.want)
+		}
+	}
+}
+
+func TestResolveOutputsCollisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     []*Page
+		generated []string
+		wantErr   string
+	}{
+		{
+			name:  "distinct",
+			pages: []*Page{testPage("a.md", PageMeta{}), testPage("b.md", PageMeta{})},
+		},
+		{
+			name:    "two pages",
+			pages:   []*Page{testPage("a.md", PageMeta{}), testPage("b.md", PageMeta{CustomURL: "a"})},
+			wantErr: "content/a.md and content/b.md both resolve to a/index.html",
+		},
+		{
+			name:      "page and generated list",
+			pages:     []*Page{testPage("book.md", PageMeta{}), testPage("book/ch1.md", PageMeta{})},
+			generated: []string{"book/index.html"},
+			wantErr:   "the generated page book/index.html would replace content/book.md",
+		},
+		{
+			name:      "two generated pages",
+			generated: []string{"tags/index.html", "tags/index.html"},
+			wantErr:   "would replace the generated page tags/index.html",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := resolveOutputs(tt.pages, config.SiteConfig{PrettyURLs: true})
+			if err == nil {
+				var generated []generatedPage
+				for _, url := range tt.generated {
+					generated = append(generated, generatedPage{url: url})
+				}
+				_, err = claimOutputs(tt.pages, generated)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveAliases(t *testing.T) {
+	page := testPage("new.md", PageMeta{Aliases: []string{"old", "older.html", "oldest/"}})
+	taken := map[string]string{"new/index.html": "content/new.md"}
+	aliases, err := resolveAliases([]*Page{page}, true, taken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outputs []string
+	for _, a := range aliases {
+		outputs = append(outputs, a.output)
+	}
+	if got, want := strings.Join(outputs, " "), "old/index.html older.html oldest/index.html"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	clash := testPage("other.md", PageMeta{Aliases: []string{"old/"}})
+	if _, err := resolveAliases([]*Page{clash}, true, taken); err == nil || !strings.Contains(err.Error(), "would replace the alias old/index.html of content/new.md") {
+		t.Errorf("got error %v, want a clash with the first alias", err)
+	}
+}
+
+func TestWriteAliases(t *testing.T) {
+	chapter := testPage("book/ch2.md", PageMeta{Title: "Two"})
+	chapter.URL = "book/ch2/"
+	home := testPage("index.md", PageMeta{Title: "Home"})
+	aliases := []alias{{output: "old-ch2/index.html", page: chapter}, {output: "start.html", page: home}}
+	tests := []struct {
+		baseURL string
+		want    []string // Canonical link and refresh target of each stub
+	}{
+		{baseURL: "/", want: []string{"../book/ch2/", "../book/ch2/", "./", "./"}},
+		{baseURL: "", want: []string{"../book/ch2/", "../book/ch2/", "./", "./"}},
+		{baseURL: "https://example.com/stories/", want: []string{"https://example.com/stories/book/ch2/", "../book/ch2/", "https://example.com/stories/", "./"}},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if _, err := writeAliases(dir, aliases, SiteData{SiteConfig: config.SiteConfig{BaseURL: tt.baseURL}}); err != nil {
+			t.Fatal(err)
+		}
+		for i, a := range aliases {
+			stub, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(a.output)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			canonical, target := tt.want[2*i], tt.want[2*i+1]
+			for _, want := range []string{`<link rel="canonical" href="` + canonical + `">`, `url=` + target + `">`} {
+				if !strings.Contains(string(stub), want) {
+					t.Errorf("baseurl %q: %s lacks %s:\n%s", tt.baseURL, a.output, want, stub)
+				}
+			}
+		}
+	}
+}
@@ -4,6 +4,8 @@ package builder
 import (
 	"bytes"
 	"fmt"
+	"nibl/internal/config"
+	"strings"
 
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/yuin/goldmark"
@@ -11,57 +13,68 @@ import (
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/util"
-	"gopkg.in/yaml.v3"
 )
 
-var (
-	markdownRenderer = goldmark.New(
+// newMarkdownRenderer builds the markdown renderer for a site. Fenced code
+// blocks are highlighted as configured in site.yaml.
+func newMarkdownRenderer(site config.SiteConfig) goldmark.Markdown {
+	options := []goldmark.Option{
 		goldmark.WithExtensions(extension.GFM, extension.Footnote),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
 			parser.WithASTTransformers(
 				util.Prioritized(newMDLinkTransformer(), 100),
+				util.Prioritized(newHeadingCollector(), 100),
 			),
 		),
 		goldmark.WithRendererOptions(
 			// The WithHardWraps() option that caused incorrect line breaks has been removed.
 			html.WithUnsafe(),
 		),
-	)
-	htmlSanitizer = bluemonday.UGCPolicy()
-)
-
-// processContent now has a simplified pipeline. It expects the markdown body
-// to have been pre-processed and is only responsible for rendering it to HTML.
-func processContent(rawContent []byte, opts BuildOptions) (PageMeta, string, error) {
-	meta := PageMeta{}
-
-	// Step 1: Separate front matter from the markdown body.
-	parts := bytes.SplitN(rawContent, []byte("---"), 3)
-	var body string
-
-	if len(parts) >= 3 {
-		if err := yaml.Unmarshal(parts[1], &meta); err != nil {
-			return PageMeta{}, "", fmt.Errorf("failed to parse front matter: %w", err)
-		}
-		body = string(parts[2])
-	} else {
-		body = string(rawContent)
 	}
+	return goldmark.New(append(options, highlightOptions(site.Highlight)...)...)
+}
 
-	// Step 2: Render the markdown body to HTML using Goldmark.
+// renderedContent is a markdown body rendered to HTML.
+type renderedContent struct {
+	html     string
+	stripped []string   // What the sanitizer removed, as listed by strippedHTML
+	headings []*Heading // Every heading, in document order
+	summary  string     // The HTML before <!--more-->, if the body has the divider
+	more     bool       // True when there is content after the divider
+}
+
+// processContent renders a markdown body (front matter already removed)
+// to HTML and sanitizes the result with policy, unless it is nil. Links to
+// other .md pages are resolved through links, which may be nil.
+func processContent(md goldmark.Markdown, body []byte, policy *bluemonday.Policy, links *linkResolver) (renderedContent, error) {
+	// Step 1: Render the markdown body to HTML using Goldmark.
+	ctx := parser.NewContext(parser.WithIDs(newHeadingIDs()))
+	ctx.Set(linkResolverKey, links)
 	var htmlBuffer bytes.Buffer
-	if err := markdownRenderer.Convert([]byte(body), &htmlBuffer); err != nil {
-		return meta, "", fmt.Errorf("failed to render markdown with goldmark: %w", err)
+	if err := md.Convert(body, &htmlBuffer, parser.WithContext(ctx)); err != nil {
+		return renderedContent{}, fmt.Errorf("failed to render markdown with goldmark: %w", err)
 	}
+	content := renderedContent{html: htmlBuffer.String()}
+	content.headings, _ = ctx.Get(headingsKey).([]*Heading)
 
-	// Step 3: Sanitize the final HTML unless the --unsafe flag is used.
-	if !opts.Unsafe {
-		sanitizedHTML := htmlSanitizer.SanitizeBytes(htmlBuffer.Bytes())
-		return meta, string(sanitizedHTML), nil
+	// Step 2: Split off the summary. The divider is an HTML comment, which
+	// goldmark passes through as it is.
+	if before, after, ok := splitSummary(content.html); ok {
+		content.html = before + after
+		content.summary = before
+		content.more = strings.TrimSpace(after) != ""
 	}
 
-	// The return statement is now corrected to match the function signature.
-	return meta, htmlBuffer.String(), nil
+	// Step 3: Sanitize the final HTML unless the page is trusted.
+	if policy == nil {
+		return content, nil
+	}
+	sanitizedHTML := policy.Sanitize(content.html)
+	content.stripped = strippedHTML([]byte(content.html), []byte(sanitizedHTML))
+	content.html = sanitizedHTML
+	if content.summary != "" {
+		content.summary = policy.Sanitize(content.summary)
+	}
+	return content, nil
 }
-
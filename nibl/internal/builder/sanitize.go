@@ -0,0 +1,133 @@
+// internal/builder/sanitize.go
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"nibl/internal/config"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+)
+
+// Trust levels a page can set with `trust:` in its front matter.
+const (
+	trustUntrusted = "untrusted" // bluemonday's UGC policy only, ignoring site.yaml
+	trustDefault   = "default"   // The site's policy
+	trustTrusted   = "trusted"   // No sanitization, like --unsafe for one page
+)
+
+// sanitizer picks the HTML policy for each page.
+type sanitizer struct {
+	base   *bluemonday.Policy
+	site   *bluemonday.Policy
+	unsafe bool // --unsafe: every page is trusted
+}
+
+// newSanitizer builds the policies for user content. The base policy is
+// bluemonday's UGC policy, which also keeps the CSS classes of highlighted
+// code blocks and the markup of footnotes; the site policy extends it with
+// the sanitize: section.
+func newSanitizer(cfg config.SanitizeConfig, unsafe bool) *sanitizer {
+	site := basePolicy()
+	if len(cfg.Elements) > 0 {
+		site.AllowElements(cfg.Elements...)
+	}
+	for element, attrs := range cfg.Attributes {
+		if len(attrs) == 0 {
+			continue
+		}
+		if element == "*" {
+			site.AllowAttrs(attrs...).Globally()
+		} else {
+			site.AllowAttrs(attrs...).OnElements(element)
+		}
+	}
+	if len(cfg.Schemes) > 0 {
+		site.AllowURLSchemes(cfg.Schemes...)
+	}
+	return &sanitizer{base: basePolicy(), site: site, unsafe: unsafe}
+}
+
+var headingID = regexp.MustCompile(`^[\p{L}\p{N}\-_:.]+$`)
+
+func basePolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("pre", "code", "span", "div", "table", "tr", "td")
+	// Heading ids keep the letters of every script, e.g. "введение". The UGC
+	// policy's global id rule only matches ASCII; a rule on the elements is
+	// tried first and, unlike a second global rule, keeps the id only once.
+	policy.AllowAttrs("id").Matching(headingID).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	// The markup goldmark writes for footnotes and aligned table columns,
+	// which would otherwise be reported as unsafe HTML on every such page.
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^footnote-(ref|backref)$`)).OnElements("a")
+	policy.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-(noteref|backlink)$`)).OnElements("a")
+	policy.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-endnotes$`)).OnElements("div")
+	policy.AllowStyles("text-align").MatchingEnum("left", "center", "right").OnElements("th", "td")
+	return policy
+}
+
+// policy returns the policy for a page's trust level, or nil when the
+// page's HTML is kept as it is.
+func (s *sanitizer) policy(trust string) (*bluemonday.Policy, error) {
+	var policy *bluemonday.Policy
+	switch trust {
+	case "", trustDefault:
+		policy = s.site
+	case trustUntrusted:
+		policy = s.base
+	case trustTrusted:
+	default:
+		return nil, fmt.Errorf("unknown trust level %q, expected %s, %s or %s", trust, trustUntrusted, trustDefault, trustTrusted)
+	}
+	if s.unsafe {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// strippedHTML compares HTML before and after sanitizing and lists the
+// elements and attributes that were removed, e.g. "<script>" or
+// "style on <p>", with a count when there was more than one. Attributes
+// are not listed separately for elements that were removed altogether.
+func strippedHTML(before, after []byte) []string {
+	removed := htmlParts(before)
+	for part, n := range htmlParts(after) {
+		removed[part] -= n
+	}
+	var stripped []string
+	for part, n := range removed {
+		if _, element, ok := strings.Cut(part, " on "); ok && removed[element] > 0 {
+			continue
+		}
+		switch {
+		case n == 1:
+			stripped = append(stripped, part)
+		case n > 1:
+			stripped = append(stripped, fmt.Sprintf("%s (%d)", part, n))
+		}
+	}
+	sort.Strings(stripped)
+	return stripped
+}
+
+// htmlParts counts the elements and element attributes of an HTML fragment.
+func htmlParts(fragment []byte) map[string]int {
+	parts := make(map[string]int)
+	z := html.NewTokenizer(bytes.NewReader(fragment))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return parts
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			parts["<"+token.Data+">"]++
+			for _, attr := range token.Attr {
+				parts[strings.ToLower(attr.Key)+" on <"+token.Data+">"]++
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// internal/builder/sanitize_test.go
+package builder
+
+import (
+	"nibl/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeReport(t *testing.T) {
+	md := newMarkdownRenderer(config.SiteConfig{})
+	tests := []struct {
+		name string
+		src  string
+		want string // What the warning lists
+	}{
+		{name: "footnotes", src: "Text.[^1]\n\n[^1]: A note."},
+		{name: "aligned table", src: "| a | b | c |\n|:-|:-:|-:|\n| 1 | 2 | 3 |"},
+		{name: "script", src: "<script>alert(1)</script>\n\nText.", want: "<script>"},
+		{name: "event handler", src: `<p onclick="x()">Hi</p>`, want: "onclick on <p>"},
+		{name: "other roles and classes", src: `<a href="x" class="btn" role="button">Go</a>`, want: "class on <a>, role on <a>"},
+		{name: "other styles", src: `<table><tr><td style="color:red">x</td></tr></table>`, want: "style on <td>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := processContent(md, []byte(tt.src), basePolicy(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(content.stripped, ", "); got != tt.want {
+				t.Errorf("got %q, want %q\n%s", got, tt.want, content.html)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// internal/builder/shortcodes.go
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// shortcodeTag matches a shortcode tag such as {{< figure src="a.jpg" >}}
+// or the closing tag {{< /aside >}}. Tags written as {{</* figure */>}}
+// are escaped and come out literally, without the comment markers.
+var shortcodeTag = regexp.MustCompile(`(?s)\{\{<\s*(/\*.*?\*/|/?[\w-]+)(.*?)\s*>\}\}`)
+
+// Shortcode is the data a shortcode template is executed with:
+// {{ .Get "src" }} or {{ .Get 0 }}, {{ .Inner }} for paired shortcodes,
+// and {{ .Page }} for the page being rendered.
+type Shortcode struct {
+	Name   string
+	Params map[string]string
+	Inner  string // Raw text between the opening and closing tag
+	Page   PageData
+
+	positional []string
+}
+
+// Get returns a named parameter, or a positional one when key is an int.
+func (s Shortcode) Get(key interface{}) string {
+	if i, ok := key.(int); ok {
+		if i >= 0 && i < len(s.positional) {
+			return s.positional[i]
+		}
+		return ""
+	}
+	return s.Params[fmt.Sprint(key)]
+}
+
+// shortcodeOutputs maps the placeholders left in the markdown to the
+// rendered shortcodes that replace them once the page is sanitized.
+type shortcodeOutputs map[string]string
+
+// shortcodeToken is one tag found in a markdown body.
+type shortcodeToken struct {
+	start, end int    // Byte offsets of the whole tag
+	name       string // Without the slash of a closing tag
+	args       string
+	closing    bool
+	escaped    bool // Written as {{</* ... */>}}
+	match      int  // Index of the closing tag of a paired shortcode, or -1
+}
+
+// tokenizeShortcodes finds every shortcode tag in src and pairs opening and
+// closing tags with a stack, so a closing tag always belongs to the nearest
+// open shortcode of the same name. Opening tags left without a closing tag
+// are standalone shortcodes.
+func tokenizeShortcodes(src string) ([]shortcodeToken, error) {
+	var tokens []shortcodeToken
+	var open []int // Indexes of opening tags not yet closed
+	for _, loc := range shortcodeTag.FindAllStringSubmatchIndex(src, -1) {
+		tok := shortcodeToken{start: loc[0], end: loc[1], name: src[loc[2]:loc[3]], args: src[loc[4]:loc[5]], match: -1}
+		switch {
+		case strings.HasPrefix(tok.name, "/*"):
+			tok.escaped = true
+		case strings.HasPrefix(tok.name, "/"):
+			tok.closing = true
+			tok.name = tok.name[1:]
+			i := len(open) - 1
+			for i >= 0 && tokens[open[i]].name != tok.name {
+				i--
+			}
+			if i < 0 || strings.TrimSpace(tok.args) != "" {
+				return nil, fmt.Errorf("shortcode closing tag %q has no opening tag", "/"+tok.name)
+			}
+			tokens[open[i]].match = len(tokens)
+			open = open[:i]
+		default:
+			open = append(open, len(tokens))
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// expandShortcodes renders every shortcode of a markdown body with the
+// theme's shortcodes/<name>.html template and replaces it by a placeholder.
+// The placeholders pass through markdown and the sanitizer untouched, so the
+// theme's trusted output is put back afterwards with restore.
+func (t *Theme) expandShortcodes(body []byte, page PageData) ([]byte, shortcodeOutputs, error) {
+	if !bytes.Contains(body, []byte("{{<")) {
+		return body, nil, nil
+	}
+	src := string(body)
+	tokens, err := tokenizeShortcodes(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outputs := make(shortcodeOutputs)
+	var out bytes.Buffer
+	last := 0 // End of the text already handled
+	for _, tok := range tokens {
+		if tok.start < last {
+			continue // Inside a paired shortcode, part of its .Inner
+		}
+		out.WriteString(src[last:tok.start])
+		last = tok.end
+		if tok.escaped {
+			// Escaped tag: keep it as text, minus the comment markers.
+			out.WriteString("{{< " + strings.TrimSpace(tok.name[2:len(tok.name)-2]+tok.args) + " >}}")
+			continue
+		}
+		sc := Shortcode{Name: tok.name, Params: make(map[string]string), Page: page}
+		if err := sc.parseArgs(tok.args); err != nil {
+			return nil, nil, fmt.Errorf("shortcode %q: %w", tok.name, err)
+		}
+		if tok.match >= 0 {
+			closing := tokens[tok.match]
+			sc.Inner = strings.TrimPrefix(src[tok.end:closing.start], "\n")
+			last = closing.end
+		}
+		html, err := t.renderShortcode(sc)
+		if err != nil {
+			return nil, nil, err
+		}
+		placeholder := "NIBLSHORTCODE" + strconv.Itoa(len(outputs)) + "END"
+		outputs[placeholder] = html
+		out.WriteString(placeholder)
+	}
+	out.WriteString(src[last:])
+	return out.Bytes(), outputs, nil
+}
+
+// parseArgs reads name="value" pairs and positional values. Values may be
+// double-quoted, single-quoted or bare words.
+func (s *Shortcode) parseArgs(args string) error {
+	args = strings.TrimSpace(args)
+	for args != "" {
+		var key string
+		if i := strings.IndexAny(args, "= \t\n\"'"); i > 0 && args[i] == '=' {
+			key, args = args[:i], args[i+1:]
+		}
+		var value string
+		switch {
+		case args == "":
+			return fmt.Errorf("missing value for %q", key)
+		case args[0] == '"' || args[0] == '\'':
+			end := strings.IndexByte(args[1:], args[0])
+			if end < 0 {
+				return fmt.Errorf("unterminated quote in %q", args)
+			}
+			value, args = args[1:end+1], args[end+2:]
+		default:
+			end := strings.IndexAny(args, " \t\n")
+			if end < 0 {
+				end = len(args)
+			}
+			value, args = args[:end], args[end:]
+		}
+		if key != "" {
+			s.Params[key] = value
+		} else {
+			s.positional = append(s.positional, value)
+		}
+		args = strings.TrimSpace(args)
+	}
+	return nil
+}
+
+// renderShortcode executes the theme's template for a shortcode.
+func (t *Theme) renderShortcode(sc Shortcode) (string, error) {
+	tmpl, ok := t.shortcodes[sc.Name]
+	if !ok {
+		return "", fmt.Errorf("unknown shortcode %q (no shortcodes/%s.html in the theme)", sc.Name, sc.Name)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, sc); err != nil {
+		return "", fmt.Errorf("failed to render shortcode %q: %w", sc.Name, err)
+	}
+	// Drop the template file's final newline so inline shortcodes stay inline.
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// restore puts the rendered shortcodes back into the page's HTML. A
+// shortcode on a line of its own ends up as a paragraph of its own, which is
+// dropped so block-level output such as <figure> is not nested inside <p>.
+func (o shortcodeOutputs) restore(html string) template.HTML {
+	for placeholder, output := range o {
+		html = strings.Replace(html, "<p>"+placeholder+"</p>", output, 1)
+		html = strings.Replace(html, placeholder, output, 1)
+	}
+	return template.HTML(html)
+}
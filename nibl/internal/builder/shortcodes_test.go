@@ -0,0 +1,79 @@
+// internal/builder/shortcodes_test.go
+package builder
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestExpandShortcodes(t *testing.T) {
+	theme := &Theme{shortcodes: map[string]*template.Template{
+		"figure": template.Must(template.New("figure").Parse(`<figure src="{{ .Get "src" }}">{{ .Get 0 }}</figure>`)),
+		"aside":  template.Must(template.New("aside").Parse(`[aside {{ .Get "class" }}|{{ .Inner }}]`)),
+	}}
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+	}{
+		{name: "no shortcodes", src: "plain {{ text }}", want: "plain {{ text }}"},
+		{name: "standalone", src: `a {{< figure src="x.jpg" >}} b`, want: `a <figure src="x.jpg"></figure> b`},
+		{name: "positional and single quotes", src: `{{< figure 'a caption' src=y.png >}}`, want: `<figure src="y.png">a caption</figure>`},
+		{name: "paired", src: "{{< aside class=\"note\" >}}\ninside{{< /aside >}}", want: "[aside note|inside]"},
+		{name: "escaped", src: `{{</* figure src="x" */>}}`, want: `{{< figure src="x" >}}`},
+		{
+			name: "nested same name",
+			src:  "{{< aside class=outer >}}a {{< aside class=inner >}}b{{< /aside >}} c{{< /aside >}} d",
+			want: "[aside outer|a {{&lt; aside class=inner &gt;}}b{{&lt; /aside &gt;}} c] d",
+		},
+		{
+			name: "unclosed opener is standalone",
+			src:  "{{< aside class=one >}} x {{< aside class=two >}}y{{< /aside >}}",
+			want: "[aside one|] x [aside two|y]",
+		},
+		{
+			name: "closer inside another pair",
+			src:  "{{< aside >}}{{< figure src=a >}}{{< /aside >}}{{< figure src=b >}}",
+			want: `[aside |{{&lt; figure src=a &gt;}}]<figure src="b"></figure>`,
+		},
+		{name: "stray closing tag", src: "text {{< /aside >}}", wantErr: `closing tag "/aside" has no opening tag`},
+		{name: "crossed tags", src: "{{< aside >}}{{< figure >}}{{< /aside >}}{{< /figure >}}", wantErr: `closing tag "/figure" has no opening tag`},
+		{name: "unknown shortcode", src: "{{< video >}}", wantErr: `unknown shortcode "video"`},
+		{name: "bad arguments", src: `{{< figure src="x >}}`, wantErr: "unterminated quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, outputs, err := theme.expandShortcodes([]byte(tt.src), PageData{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(outputs.restore(string(body))); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	sc := Shortcode{Params: make(map[string]string)}
+	if err := sc.parseArgs(` first src="a b.jpg" alt='single' width=300 "last one" `); err != nil {
+		t.Fatal(err)
+	}
+	if sc.Get(0) != "first" || sc.Get(1) != "last one" || sc.Get(2) != "" {
+		t.Errorf("positional = %q", sc.positional)
+	}
+	if sc.Get("src") != "a b.jpg" || sc.Get("width") != "300" {
+		t.Errorf("params = %v", sc.Params)
+	}
+	if err := sc.parseArgs(`key=`); err == nil {
+		t.Error("expected an error for a key without a value")
+	}
+}
@@ -0,0 +1,87 @@
+// internal/builder/sitemap.go
+package builder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"nibl/internal/util"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	sitemapFile = "sitemap.xml"
+	robotsFile  = "robots.txt"
+)
+
+type sitemapDoc struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	Lastmod string `xml:"lastmod,omitempty"`
+}
+
+// writeSitemap writes sitemap.xml listing every published page and generated
+// page, followed by a robots.txt pointing to it. Pages opt out with
+// `sitemap: false`; drafts are always left out, story state variants when
+// configured.
+// A robots.txt in the static directory takes precedence over the generated one.
+// Both files need absolute URLs, so neither is written without an absolute
+// baseurl.
+func writeSitemap(outputDir, staticDir string, site SiteData, generated []generatedPage) ([]string, error) {
+	if site.Sitemap.Disabled {
+		return nil, nil
+	}
+	if !util.IsAbsoluteURL(site.BaseURL) {
+		fmt.Printf("Warning: baseurl %q is not an absolute URL; skipping %s and %s.\n", site.BaseURL, sitemapFile, robotsFile)
+		return nil, nil
+	}
+
+	var doc sitemapDoc
+	for _, page := range site.Pages {
+		if page.Draft || page.Sitemap != nil && !*page.Sitemap {
+			continue
+		}
+		if page.Variant && site.Sitemap.ExcludeVariants {
+			continue
+		}
+		entry := sitemapURL{Loc: sitemapLoc(site.BaseURL, page.URL)}
+		if !page.Lastmod.IsZero() {
+			entry.Lastmod = page.Lastmod.Format(time.RFC3339)
+		} else if !page.modTime.IsZero() {
+			entry.Lastmod = page.modTime.Format(time.RFC3339)
+		}
+		doc.URLs = append(doc.URLs, entry)
+	}
+	for _, gen := range generated {
+		doc.URLs = append(doc.URLs, sitemapURL{Loc: sitemapLoc(site.BaseURL, gen.url)})
+	}
+
+	if err := writeXML(outputDir, sitemapFile, doc); err != nil {
+		return nil, err
+	}
+	written := []string{sitemapFile}
+
+	if _, err := os.Stat(filepath.Join(staticDir, robotsFile)); err == nil {
+		return written, nil
+	}
+	robots := fmt.Sprintf("User-agent: *\nAllow: /\n\nSitemap: %s\n", util.AbsURL(site.BaseURL, sitemapFile))
+	if err := os.WriteFile(filepath.Join(outputDir, robotsFile), []byte(robots), 0644); err != nil {
+		return nil, err
+	}
+	return append(written, robotsFile), nil
+}
+
+// sitemapLoc returns the absolute URL of an output, addressing index pages
+// by their directory as web servers do.
+func sitemapLoc(baseURL, url string) string {
+	if url == "index.html" || strings.HasSuffix(url, "/index.html") {
+		url = strings.TrimSuffix(url, "index.html")
+	}
+	return util.AbsURL(baseURL, url)
+}
@@ -0,0 +1,66 @@
+// internal/builder/sitemap_test.go
+package builder
+
+import (
+	"nibl/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteSitemap(t *testing.T) {
+	page := testPage("book/ch1.md", PageMeta{})
+	page.URL = "book/ch1.html"
+	draft := testPage("book/ch2.md", PageMeta{Draft: true})
+	draft.URL = "book/ch2.html"
+	generated := []generatedPage{{url: "book/index.html"}}
+	tests := []struct {
+		baseURL string
+		want    []string // What sitemap.xml and robots.txt contain, nothing when they are skipped
+	}{
+		{
+			baseURL: "https://example.com/story/",
+			want: []string{
+				"<loc>https://example.com/story/book/ch1.html</loc>",
+				"<loc>https://example.com/story/book/</loc>",
+				"Sitemap: https://example.com/story/sitemap.xml",
+			},
+		},
+		{baseURL: "/"},
+		{baseURL: ""},
+		{baseURL: "/story/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			dir := t.TempDir()
+			site := SiteData{SiteConfig: config.SiteConfig{BaseURL: tt.baseURL}, Pages: Pages{page, draft}}
+			written, err := writeSitemap(dir, filepath.Join(dir, "static"), site, generated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.want) == 0 {
+				if len(written) > 0 {
+					t.Errorf("wrote %v without an absolute baseurl", written)
+				}
+				return
+			}
+			var all string
+			for _, name := range written {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				all += string(data)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(all, want) {
+					t.Errorf("%v lack %s:\n%s", written, want, all)
+				}
+			}
+			if strings.Contains(all, "ch2") {
+				t.Errorf("the draft is listed:\n%s", all)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// internal/builder/static.go
+package builder
+
+import (
+	"fmt"
+	"io"
+	"nibl/internal/config"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// copyStaticAssets copies files from the static directory to the output
+// directory, as selected by the `static:` section of site.yaml. Files that
+// are skipped get a warning, and files whose copy is already up to date are
+// left alone. It returns the slash-separated outputs it is responsible for.
+func copyStaticAssets(staticDir, outputDir string, cfg config.StaticConfig, handled map[string]bool) ([]string, error) {
+	selection, err := newStaticSelection(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(staticDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var copied []string
+	err = walkStatic(staticDir, func(rel, p string, info os.FileInfo) error {
+		if reason, ok := selection.selects(rel); !ok {
+			fmt.Printf("Warning: static file %s not copied, %s.\n", rel, reason)
+			return nil
+		}
+		if handled[rel] {
+			return nil // Written by the asset pipeline
+		}
+		if err := copyIfChanged(p, filepath.Join(outputDir, filepath.FromSlash(rel)), info); err != nil {
+			return fmt.Errorf("failed to copy static file %s: %w", rel, err)
+		}
+		copied = append(copied, rel)
+		return nil
+	})
+	return copied, err
+}
+
+// walkStatic calls fn for every file below staticDir with its slash path.
+func walkStatic(staticDir string, fn func(rel, p string, info os.FileInfo) error) error {
+	return filepath.Walk(staticDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(staticDir, p)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), p, info)
+	})
+}
+
+// staticSelection applies the include and exclude patterns of site.yaml.
+type staticSelection struct {
+	include, exclude globs
+}
+
+func newStaticSelection(cfg config.StaticConfig) (staticSelection, error) {
+	include, err := compileGlobs(cfg.Include)
+	if err != nil {
+		return staticSelection{}, fmt.Errorf("invalid static include pattern: %w", err)
+	}
+	exclude, err := compileGlobs(cfg.Exclude)
+	if err != nil {
+		return staticSelection{}, fmt.Errorf("invalid static exclude pattern: %w", err)
+	}
+	return staticSelection{include: include, exclude: exclude}, nil
+}
+
+// selects reports whether a static file is published, and why not otherwise.
+func (s staticSelection) selects(rel string) (string, bool) {
+	if pattern, ok := s.exclude.match(rel); ok {
+		return fmt.Sprintf("it matches exclude pattern %q", pattern), false
+	}
+	if len(s.include) > 0 {
+		if _, ok := s.include.match(rel); !ok {
+			return "it matches no include pattern", false
+		}
+	}
+	return "", true
+}
+
+// copyIfChanged copies src to dest unless dest already has the same size
+// and modification time. The copy takes over src's modification time, so an
+// unchanged file is recognised on the next build.
+func copyIfChanged(src, dest string, info os.FileInfo) error {
+	if existing, err := os.Stat(dest); err == nil && existing.Mode().IsRegular() &&
+		existing.Size() == info.Size() && existing.ModTime().Equal(info.ModTime()) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// glob is a compiled static include or exclude pattern.
+type glob struct {
+	pattern string
+	re      *regexp.Regexp // Matches the whole path, for patterns with a slash
+}
+
+type globs []glob
+
+// compileGlobs checks the patterns and compiles those that contain a slash.
+func compileGlobs(patterns []string) (globs, error) {
+	var compiled globs
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(strings.TrimSuffix(pattern, "/"), "/")
+		if !strings.Contains(pattern, "/") {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%q: %w", pattern, err)
+			}
+			compiled = append(compiled, glob{pattern: pattern})
+			continue
+		}
+		re, err := globRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", pattern, err)
+		}
+		compiled = append(compiled, glob{pattern: pattern, re: re})
+	}
+	return compiled, nil
+}
+
+// match returns the first pattern that matches rel. A pattern with a slash
+// matches the path itself or one of its parent directories; a pattern
+// without one matches any single path segment.
+func (gs globs) match(rel string) (string, bool) {
+	segments := strings.Split(rel, "/")
+	for _, g := range gs {
+		if g.re != nil {
+			for i := len(segments); i > 0; i-- {
+				if g.re.MatchString(strings.Join(segments[:i], "/")) {
+					return g.pattern, true
+				}
+			}
+			continue
+		}
+		for _, segment := range segments {
+			if ok, _ := path.Match(g.pattern, segment); ok {
+				return g.pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// globRegexp translates a slash pattern to a regular expression: ** matches
+// across directories, * and ? stay within one, and [...] is a character class.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				re.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				re.WriteString(".*")
+				i++
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, path.ErrBadPattern
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
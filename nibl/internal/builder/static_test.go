@@ -0,0 +1,51 @@
+// internal/builder/static_test.go
+package builder
+
+import (
+	"nibl/internal/config"
+	"testing"
+)
+
+func TestStaticSelection(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.StaticConfig
+		rel     string
+		want    bool
+		wantWhy string
+	}{
+		{name: "everything by default", rel: "css/site.css", want: true},
+		{name: "segment pattern", cfg: config.StaticConfig{Exclude: []string{"*.psd"}}, rel: "images/raw/map.psd", wantWhy: `it matches exclude pattern "*.psd"`},
+		{name: "segment pattern matches directories", cfg: config.StaticConfig{Exclude: []string{".git"}}, rel: "vendor/.git/HEAD", wantWhy: `it matches exclude pattern ".git"`},
+		{name: "slash pattern is anchored", cfg: config.StaticConfig{Exclude: []string{"raw/*"}}, rel: "images/raw/map.jpg", want: true},
+		{name: "slash pattern covers subdirectories", cfg: config.StaticConfig{Exclude: []string{"/images/raw/"}}, rel: "images/raw/2024/map.jpg", wantWhy: `it matches exclude pattern "images/raw"`},
+		{name: "single star stays in one directory", cfg: config.StaticConfig{Exclude: []string{"images/*.jpg"}}, rel: "images/sub/a.jpg", want: true},
+		{name: "double star crosses directories", cfg: config.StaticConfig{Exclude: []string{"images/**/*.jpg"}}, rel: "images/sub/deep/a.jpg", wantWhy: `it matches exclude pattern "images/**/*.jpg"`},
+		{name: "double star matches no directory", cfg: config.StaticConfig{Exclude: []string{"images/**/*.jpg"}}, rel: "images/a.jpg", wantWhy: `it matches exclude pattern "images/**/*.jpg"`},
+		{name: "question mark and class", cfg: config.StaticConfig{Exclude: []string{"docs/v?/[!a]*.md"}}, rel: "docs/v1/notes.md", wantWhy: `it matches exclude pattern "docs/v?/[!a]*.md"`},
+		{name: "class excludes", cfg: config.StaticConfig{Exclude: []string{"docs/v?/[!a]*.md"}}, rel: "docs/v1/about.md", want: true},
+		{name: "include limits", cfg: config.StaticConfig{Include: []string{"css/**", "*.ico"}}, rel: "js/app.js", wantWhy: "it matches no include pattern"},
+		{name: "include matches", cfg: config.StaticConfig{Include: []string{"css/**", "*.ico"}}, rel: "favicon.ico", want: true},
+		{name: "exclude beats include", cfg: config.StaticConfig{Include: []string{"css/**"}, Exclude: []string{"*.map"}}, rel: "css/site.css.map", wantWhy: `it matches exclude pattern "*.map"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection, err := newStaticSelection(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			why, ok := selection.selects(tt.rel)
+			if ok != tt.want || why != tt.wantWhy {
+				t.Errorf("selects(%q) = %q, %v; want %q, %v", tt.rel, why, ok, tt.wantWhy, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticSelectionInvalid(t *testing.T) {
+	for _, pattern := range []string{"[", "images/[a-"} {
+		if _, err := newStaticSelection(config.StaticConfig{Exclude: []string{pattern}}); err == nil {
+			t.Errorf("pattern %q: expected an error", pattern)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// internal/builder/summary.go
+package builder
+
+import (
+	"html/template"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// moreDivider ends the summary of a page when it sits on a line of its own.
+const moreDivider = "<!--more-->"
+
+const (
+	defaultSummaryLength = 70  // Words in an automatic summary
+	wordsPerMinute       = 200 // Reading speed behind ReadingTime
+)
+
+// readingTime estimates the minutes it takes to read a number of words,
+// rounding up so that any text takes at least a minute.
+func readingTime(words int) int {
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// voidElements never have an end tag, so they do not nest other elements.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// splitSummary cuts rendered content at the first <!--more--> divider that
+// stands on a line of its own outside of any element, which is where
+// markdown puts a divider written as a paragraph of its own. A divider
+// inside a paragraph, list or quote is ignored, so the summary never ends
+// with an unclosed element.
+func splitSummary(content string) (before, after string, ok bool) {
+	z := html.NewTokenizer(strings.NewReader(content))
+	offset, depth := 0, 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return content, "", false
+		}
+		start := offset
+		offset += len(z.Raw())
+		switch tt {
+		case html.StartTagToken:
+			if name, _ := z.TagName(); !voidElements[string(name)] {
+				depth++
+			}
+		case html.EndTagToken:
+			if depth > 0 {
+				depth--
+			}
+		case html.CommentToken:
+			if depth > 0 || z.Token().Data != "more" {
+				continue
+			}
+			lineStart := start == 0 || content[start-1] == '\n'
+			lineEnd := offset == len(content) || content[offset] == '\n'
+			if lineStart && lineEnd && content[start:offset] == moreDivider {
+				return content[:start], content[offset:], true
+			}
+		}
+	}
+}
+
+// autoSummary returns the first words of a page's rendered content as plain
+// text, for pages without a <!--more--> divider, and whether it was cut short.
+func autoSummary(content string, length int) (template.HTML, bool) {
+	if length <= 0 {
+		length = defaultSummaryLength
+	}
+	words := strings.Fields(plainify(content))
+	if len(words) <= length {
+		return template.HTML(template.HTMLEscapeString(strings.Join(words, " "))), false
+	}
+	text := strings.TrimRight(strings.Join(words[:length], " "), ".,;:")
+	return template.HTML(template.HTMLEscapeString(text) + "…"), true
+}
+
+// summaryFor returns the summary of page with its relative links rewritten
+// from the page's location to that of listing, e.g. "../ch2/" in the summary
+// of book/ch1/ to "book/ch2/" on the home page. It backs the summary
+// template function, so summaries can be listed at any depth.
+func summaryFor(listing PageData, page *Page) template.HTML {
+	if page == nil {
+		return ""
+	}
+	return rebaseLinks(page.Summary, page, listing.BaseHref)
+}
+
+// rebaseLinks rewrites the relative links of an HTML fragment written for
+// page so they work from a page whose BaseHref is baseHref. Root-absolute
+// and absolute links are kept as they are.
+func rebaseLinks(fragment template.HTML, page *Page, baseHref string) template.HTML {
+	rewrite := func(link string) string {
+		u, err := url.Parse(link)
+		if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(u.Path, "/") {
+			return link
+		}
+		target := page.URL
+		if u.Path != "" {
+			target = path.Join(path.Dir(page.outputPath), u.Path)
+			if strings.HasSuffix(u.Path, "/") {
+				target += "/"
+			}
+		}
+		u.Path = baseHref + strings.TrimPrefix(target, "./")
+		if u.Path == "" {
+			u.Path = "./"
+		}
+		return u.String()
+	}
+
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(string(fragment)))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return template.HTML(b.String())
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			b.Write(z.Raw())
+			continue
+		}
+		raw := string(z.Raw())
+		token := z.Token()
+		attrs := linkAttrs[token.Data]
+		changed := false
+		for i, attr := range token.Attr {
+			if !containsString(attrs, attr.Key) {
+				continue
+			}
+			if attr.Key == "srcset" {
+				candidates := strings.Split(attr.Val, ",")
+				for j, candidate := range candidates {
+					if fields := strings.Fields(candidate); len(fields) > 0 {
+						fields[0] = rewrite(fields[0])
+						candidates[j] = strings.Join(fields, " ")
+					}
+				}
+				token.Attr[i].Val = strings.Join(candidates, ", ")
+			} else {
+				token.Attr[i].Val = rewrite(attr.Val)
+			}
+			changed = changed || token.Attr[i].Val != attr.Val
+		}
+		if changed {
+			b.WriteString(token.String())
+		} else {
+			b.WriteString(raw)
+		}
+	}
+}
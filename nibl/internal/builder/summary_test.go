@@ -0,0 +1,111 @@
+// internal/builder/summary_test.go
+package builder
+
+import (
+	"html/template"
+	"nibl/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestSummarySplit(t *testing.T) {
+	md := newMarkdownRenderer(config.SiteConfig{})
+	tests := []struct {
+		name    string
+		src     string
+		summary string // Empty when the page has no manual summary
+		more    bool
+	}{
+		{name: "no divider", src: "One.\n\nTwo."},
+		{name: "own line", src: "One.\n\n<!--more-->\n\nTwo.", summary: "<p>One.</p>\n", more: true},
+		{name: "at the end", src: "One.\n\n<!--more-->", summary: "<p>One.</p>\n", more: false},
+		{name: "right after a paragraph", src: "One.\n<!--more-->\n\nTwo.", summary: "<p>One.</p>\n", more: true},
+		{name: "inline", src: "One <!--more--> two.", summary: ""},
+		{name: "trailing text", src: "One.\n\n<!--more--> two\n\nThree.", summary: ""},
+		{name: "inside a quote", src: "> One.\n>\n> <!--more-->\n>\n> Two.", summary: ""},
+		{name: "inside a list", src: "- One\n\n  <!--more-->\n\n- Two", summary: ""},
+		{name: "first top-level one", src: "> <!--more-->\n\nOne.\n\n<!--more-->\n\nTwo.", summary: "<blockquote>\n<!--more-->\n</blockquote>\n<p>One.</p>\n", more: true},
+		{name: "in a code block", src: "```\n<!--more-->\n```\n\nText.", summary: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := processContent(md, []byte(tt.src), nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content.summary != tt.summary || content.more != tt.more {
+				t.Errorf("got summary %q (more %v), want %q (more %v)", content.summary, content.more, tt.summary, tt.more)
+			}
+			if tt.summary != "" && strings.Contains(content.html, moreDivider) != strings.Contains(tt.summary, moreDivider) {
+				t.Errorf("divider left in the content: %q", content.html)
+			}
+		})
+	}
+}
+
+func TestAutoSummary(t *testing.T) {
+	tests := []struct {
+		content   string
+		length    int
+		want      string
+		truncated bool
+	}{
+		{content: "<p>Short &amp; sweet.</p>", length: 5, want: "Short &amp; sweet."},
+		{content: "<p>one two three four</p>", length: 3, want: "one two three…", truncated: true},
+		{content: "<p>one, two. three</p>", length: 2, want: "one, two…", truncated: true},
+		{content: "<p>a <em>b</em></p>\n<p>c</p>", length: 0, want: "a b c"},
+	}
+	for _, tt := range tests {
+		got, truncated := autoSummary(tt.content, tt.length)
+		if string(got) != tt.want || truncated != tt.truncated {
+			t.Errorf("autoSummary(%q, %d) = %q, %v; want %q, %v", tt.content, tt.length, got, truncated, tt.want, tt.truncated)
+		}
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	for words, want := range map[int]int{0: 0, 1: 1, 200: 1, 201: 2, 1000: 5} {
+		if got := readingTime(words); got != want {
+			t.Errorf("readingTime(%d) = %d, want %d", words, got, want)
+		}
+	}
+}
+
+func TestSummaryFor(t *testing.T) {
+	page := &Page{URL: "book/ch1/", outputPath: "book/ch1/index.html"}
+	page.Summary = template.HTML(`<p><a href="../ch2/">Next</a> <a href="#part">Part</a> <a href="/x">Abs</a> ` +
+		`<a href="https://example.org/">Out</a> <img src="map.png" srcset="map-480.png 480w, map-800.png 800w"></p>`)
+	tests := []struct {
+		name    string
+		listing string // BaseHref of the listing page
+		want    string
+	}{
+		{
+			name: "home page",
+			want: `<p><a href="book/ch2/">Next</a> <a href="book/ch1/#part">Part</a> <a href="/x">Abs</a> ` +
+				`<a href="https://example.org/">Out</a> <img src="book/ch1/map.png" srcset="book/ch1/map-480.png 480w, book/ch1/map-800.png 800w"></p>`,
+		},
+		{
+			name:    "section page",
+			listing: "../",
+			want: `<p><a href="../book/ch2/">Next</a> <a href="../book/ch1/#part">Part</a> <a href="/x">Abs</a> ` +
+				`<a href="https://example.org/">Out</a> <img src="../book/ch1/map.png" srcset="../book/ch1/map-480.png 480w, ../book/ch1/map-800.png 800w"></p>`,
+		},
+		{
+			name:    "the page itself",
+			listing: "../../",
+			want: `<p><a href="../../book/ch2/">Next</a> <a href="../../book/ch1/#part">Part</a> <a href="/x">Abs</a> ` +
+				`<a href="https://example.org/">Out</a> <img src="../../book/ch1/map.png" srcset="../../book/ch1/map-480.png 480w, ../../book/ch1/map-800.png 800w"></p>`,
+		},
+	}
+	for _, tt := range tests {
+		if got := string(summaryFor(PageData{BaseHref: tt.listing}, page)); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+
+	home := &Page{outputPath: "index.html", Summary: `<a href="#top">Top</a> <a href="book/">Book</a>`}
+	if got, want := string(summaryFor(PageData{}, home)), `<a href="./#top">Top</a> <a href="book/">Book</a>`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
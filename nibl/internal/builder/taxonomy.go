@@ -0,0 +1,129 @@
+// internal/builder/taxonomy.go
+package builder
+
+import (
+	"fmt"
+	"nibl/internal/util"
+	"sort"
+	"strings"
+)
+
+// Term is a single value of a taxonomy, e.g. "Mara" in characters,
+// together with every page that carries it.
+type Term struct {
+	Name  string // As first written in front matter
+	Slug  string
+	URL   string // Link to the term page, e.g. "characters/mara/index.html"
+	Pages Pages
+
+	outputPath string
+}
+
+// Terms is the list of terms of one taxonomy, ordered by name.
+type Terms []*Term
+
+// ByCount returns the terms ordered by how many pages carry them, most first.
+func (ts Terms) ByCount() Terms {
+	sorted := append(Terms(nil), ts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Pages) > len(sorted[j].Pages) })
+	return sorted
+}
+
+// buildTaxonomies collects the terms of every configured taxonomy from the
+// front matter of the given pages. Terms are matched by slug, so "Mara" and
+// "mara" end up on the same page. Term pages are placed below dir, the
+// directory of the pages' language.
+func buildTaxonomies(names []string, pages Pages, pretty bool, dir string) map[string]Terms {
+	taxonomies := make(map[string]Terms, len(names))
+	for _, name := range names {
+		bySlug := make(map[string]*Term)
+		var terms Terms
+		for _, page := range pages {
+			for _, value := range termValues(page.Params[name]) {
+				slug := util.Slugify(value)
+				if slug == "" {
+					continue
+				}
+				term, ok := bySlug[slug]
+				if !ok {
+					output := dir + name + "/" + slug + "/index.html"
+					term = &Term{Name: value, Slug: slug, URL: linkFor(output, pretty), outputPath: output}
+					bySlug[slug] = term
+					terms = append(terms, term)
+				}
+				if len(term.Pages) == 0 || term.Pages[len(term.Pages)-1] != page {
+					term.Pages = append(term.Pages, page)
+				}
+			}
+		}
+		sort.Slice(terms, func(i, j int) bool {
+			return strings.ToLower(terms[i].Name) < strings.ToLower(terms[j].Name)
+		})
+		taxonomies[name] = terms
+	}
+	return taxonomies
+}
+
+// termValues normalises a taxonomy front matter value. Both YAML lists and
+// comma-separated strings are accepted; the latter is what knot comments
+// such as `// tags: mara, forest` produce.
+func termValues(v interface{}) []string {
+	var raw []string
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for _, item := range val {
+			raw = append(raw, fmt.Sprint(item))
+		}
+	case string:
+		raw = strings.Split(val, ",")
+	default:
+		raw = []string{fmt.Sprint(val)}
+	}
+
+	var values []string
+	for _, value := range raw {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// taxonomyPages builds a term list page for every configured taxonomy and
+// one page per term. The "terms" and "term" layouts are used when the
+// theme defines them, otherwise both fall back to "list".
+func taxonomyPages(site SiteData, theme *Theme) []generatedPage {
+	var generated []generatedPage
+	for _, name := range site.SiteConfig.Taxonomies {
+		terms := site.Taxonomies[name]
+		termsLayout := layoutQuery{kind: "terms", typ: name}
+		termLayout := layoutQuery{kind: "term", typ: name}
+		if !theme.has(termsLayout) || !theme.has(termLayout) {
+			continue
+		}
+
+		// The term list page also gets one .Pages entry per term, so a
+		// plain list template can render it.
+		entries := make(Pages, len(terms))
+		for i, term := range terms {
+			entries[i] = &Page{PageMeta: PageMeta{Title: term.Name}, URL: term.URL, Section: name, IsSection: true, outputPath: term.outputPath}
+		}
+		listOutput := site.Language.Dir + name + "/index.html"
+		listPage := &Page{PageMeta: PageMeta{Title: titleCase(name)}, URL: linkFor(listOutput, site.PrettyURLs), outputPath: listOutput}
+		data := newPageData(listPage, site)
+		data.Pages = entries
+		data.Terms = terms
+		generated = append(generated, generatedPage{url: listOutput, layout: termsLayout, data: data})
+
+		for _, term := range terms {
+			termPage := &Page{PageMeta: PageMeta{Title: term.Name}, URL: term.URL, Section: name, outputPath: term.outputPath}
+			data := newPageData(termPage, site)
+			data.Pages = term.Pages
+			data.Term = term
+			generated = append(generated, generatedPage{url: term.outputPath, layout: termLayout, data: data})
+		}
+	}
+	return generated
+}
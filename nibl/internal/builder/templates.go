@@ -0,0 +1,286 @@
+// internal/builder/templates.go
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Theme is a parsed theme directory. Every layout is parsed into its own
+// template set together with the shared partials, so each layout file can
+// define "main" without clashing with the others.
+//
+// A theme is laid out as:
+//
+//	layout.html, list.html, terms.html, term.html   default layouts (legacy location)
+//	404.html                                         the not-found page, if the theme has one
+//	header.html, footer.html                         partials used by the defaults
+//	partials/**/*.html                               extra partials, loaded automatically
+//	layouts/[<type or section>/]<name>.html          layouts picked by lookup
+//	shortcodes/<name>.html                           shortcodes used in content
+//	i18n/<language code>.yaml                        strings for the i18n template function
+type Theme struct {
+	layouts    map[string]*themeLayout       // Keyed by slash path relative to the theme dir
+	shortcodes map[string]*template.Template // Keyed by shortcode name
+	funcs      *templateFuncs
+	minify     bool
+}
+
+// themeLayout is one layout file parsed together with the partials.
+type themeLayout struct {
+	set   *template.Template
+	entry string // Name of the template to execute
+}
+
+// layoutKinds lists, for every kind of page, the layout names tried in
+// order and the legacy file at the theme root that serves as last resort.
+var layoutKinds = map[string][]string{
+	"single": {"single"},
+	"list":   {"list"},
+	"terms":  {"terms", "list"},
+	"term":   {"term", "list"},
+	"404":    {"404"},
+}
+
+// legacyLayouts maps layout names to the theme-root files nibl has always used.
+var legacyLayouts = map[string]string{
+	"single": "layout.html",
+	"list":   "list.html",
+	"terms":  "terms.html",
+	"term":   "term.html",
+	"404":    "404.html",
+}
+
+// layoutQuery describes the page a layout is looked up for.
+type layoutQuery struct {
+	kind    string // single, list, terms, term or 404
+	layout  string // From the `layout:` front matter key, may be empty
+	typ     string // From the `type:` front matter key, may be empty
+	section string // Slash-separated content section, "" for the root
+}
+
+// LoadTemplates parses every layout and partial of a given theme directory.
+func LoadTemplates(templateDir, templateName string) (*Theme, error) {
+	root := filepath.Join(templateDir, templateName)
+
+	// Shared partials: the classic header and footer plus anything under partials/.
+	funcs := &templateFuncs{}
+	partials := template.New("").Funcs(funcs.funcMap())
+	for _, name := range []string{"header.html", "footer.html"} {
+		if err := parseTemplateFile(partials, root, name); err != nil {
+			return nil, err
+		}
+	}
+	if err := walkTemplates(root, "partials", func(rel string) error {
+		return parseTemplateFile(partials, root, rel)
+	}); err != nil {
+		return nil, err
+	}
+
+	theme := &Theme{
+		layouts:    make(map[string]*themeLayout),
+		shortcodes: make(map[string]*template.Template),
+		funcs:      funcs,
+	}
+	addLayout := func(rel string) error {
+		set, err := partials.Clone()
+		if err != nil {
+			return err
+		}
+		if err := parseTemplateFile(set, root, rel); err != nil {
+			return err
+		}
+		theme.layouts[rel] = &themeLayout{set: set, entry: layoutEntry(set, rel)}
+		return nil
+	}
+	for _, rel := range legacyLayouts {
+		if _, err := os.Stat(filepath.Join(root, rel)); err == nil {
+			if err := addLayout(rel); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := walkTemplates(root, "layouts", addLayout); err != nil {
+		return nil, err
+	}
+	if err := walkTemplates(root, "shortcodes", func(rel string) error {
+		set, err := partials.Clone()
+		if err != nil {
+			return err
+		}
+		if err := parseTemplateFile(set, root, rel); err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(rel, "shortcodes/"), ".html")
+		theme.shortcodes[name] = set.Lookup(rel)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	translations, err := loadTranslations(filepath.Join(root, "i18n"))
+	if err != nil {
+		return nil, err
+	}
+	funcs.translations = translations
+
+	if !theme.has(layoutQuery{kind: "single"}) {
+		return nil, fmt.Errorf("theme %s has no default layout (layout.html or layouts/single.html)", root)
+	}
+	return theme, nil
+}
+
+// parseTemplateFile parses a file into set under its slash path relative to
+// the theme root, e.g. "partials/nav.html". Missing header/footer files are
+// tolerated so minimal themes work.
+func parseTemplateFile(set *template.Template, root, rel string) error {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+	if os.IsNotExist(err) && !strings.Contains(rel, "/") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := set.New(rel).Parse(string(data)); err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", rel, err)
+	}
+	return nil
+}
+
+// loadTranslations reads the string tables in a theme's i18n directory,
+// one YAML map per language, e.g. i18n/es.yaml holding `home: Inicio`.
+func loadTranslations(dir string) (map[string]map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	translations := make(map[string]map[string]string)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var table map[string]string
+		if err := yaml.Unmarshal(data, &table); err != nil {
+			return nil, fmt.Errorf("failed to parse i18n/%s: %w", entry.Name(), err)
+		}
+		translations[strings.TrimSuffix(entry.Name(), ext)] = table
+	}
+	return translations, nil
+}
+
+// walkTemplates calls fn with the slash path of every .html file below dir.
+func walkTemplates(root, dir string, fn func(rel string) error) error {
+	start := filepath.Join(root, dir)
+	if _, err := os.Stat(start); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(start, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(p) != ".html" {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel))
+	})
+}
+
+// layoutEntry picks the template to execute for a layout file: "main" or
+// the kind's own name when the file defines one (as the classic layout.html
+// and list.html do), otherwise the file itself.
+func layoutEntry(set *template.Template, rel string) string {
+	candidates := []string{"main", strings.TrimSuffix(path.Base(rel), ".html")}
+	for _, name := range candidates {
+		if t := set.Lookup(name); t != nil && t.Tree != nil {
+			return name
+		}
+	}
+	return rel
+}
+
+// resolve finds the layout for a page. Names are tried from most to least
+// specific: the `layout:` key, then the kind's defaults. For each name the
+// type directory is searched first, then the section and its ancestors,
+// then layouts/ itself. The legacy files at the theme root come last.
+func (t *Theme) resolve(q layoutQuery) (*themeLayout, bool) {
+	var dirs []string
+	if q.typ != "" {
+		dirs = append(dirs, q.typ)
+	}
+	for dir := q.section; dir != ""; dir = sectionOf(dir) {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, "")
+
+	var names []string
+	if q.layout != "" {
+		names = append(names, q.layout)
+	}
+	names = append(names, layoutKinds[q.kind]...)
+
+	for _, name := range names {
+		for _, dir := range dirs {
+			if layout, ok := t.layouts[path.Join("layouts", dir, name+".html")]; ok {
+				return layout, true
+			}
+		}
+	}
+	for _, name := range layoutKinds[q.kind] {
+		if layout, ok := t.layouts[legacyLayouts[name]]; ok {
+			return layout, true
+		}
+	}
+	return nil, false
+}
+
+// prepare hands the theme what it needs to know about the build in progress.
+// It must be called before rendering starts, as rendering runs in parallel.
+func (t *Theme) prepare(site SiteData, assets assetSet, images *imageProcessor) {
+	t.funcs.setSite(site)
+	t.funcs.assets = assets
+	t.funcs.images = images
+	t.minify = site.Assets.Minify
+}
+
+// has reports whether any layout matches the query.
+func (t *Theme) has(q layoutQuery) bool {
+	_, ok := t.resolve(q)
+	return ok
+}
+
+// render executes the layout found for q and writes the output to a file,
+// minified when the asset pipeline asks for it.
+func (t *Theme) render(q layoutQuery, outPath string, data PageData) error {
+	layout, ok := t.resolve(q)
+	if !ok {
+		return fmt.Errorf("no %s layout found", q.kind)
+	}
+	var out bytes.Buffer
+	if err := layout.set.ExecuteTemplate(&out, layout.entry, data); err != nil {
+		return err
+	}
+	html := out.Bytes()
+	if t.minify {
+		html = minifyHTML(html)
+	}
+	return os.WriteFile(outPath, html, 0644)
+}
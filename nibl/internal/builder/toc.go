@@ -0,0 +1,86 @@
+// internal/builder/toc.go
+package builder
+
+import (
+	"html/template"
+	"strings"
+)
+
+// defaultTocDepth is the number of heading levels listed in a table of
+// contents when the page does not set `tocDepth:`.
+const defaultTocDepth = 3
+
+// Heading is an entry of a page's table of contents. Templates reach the
+// tree through .Headings, e.g. to build their own in-page navigation.
+type Heading struct {
+	Level    int    // 1 for <h1>, 2 for <h2> and so on
+	ID       string // The heading's anchor, e.g. "the-garden"
+	Title    string // The heading as plain text
+	Children []*Heading
+}
+
+// headingTree nests the headings of a page under each other. Only depth
+// levels are kept, counted from the page's highest heading, so a chapter
+// written with ## and ### lists both at the default depth of three.
+func headingTree(headings []*Heading, depth int) []*Heading {
+	if len(headings) == 0 {
+		return nil
+	}
+	if depth <= 0 {
+		depth = defaultTocDepth
+	}
+	top := headings[0].Level
+	for _, h := range headings {
+		if h.Level < top {
+			top = h.Level
+		}
+	}
+
+	var roots, stack []*Heading
+	for _, h := range headings {
+		if h.Level >= top+depth || h.ID == "" {
+			continue
+		}
+		entry := &Heading{Level: h.Level, ID: h.ID, Title: h.Title}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+	}
+	return roots
+}
+
+// renderTOC renders a heading tree as nested lists linking each heading,
+// wrapped in <nav class="toc">. It is empty for pages without headings.
+func renderTOC(headings []*Heading) template.HTML {
+	if len(headings) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<nav class="toc">`)
+	writeTOCList(&b, headings)
+	b.WriteString(`</nav>`)
+	return template.HTML(b.String())
+}
+
+func writeTOCList(b *strings.Builder, headings []*Heading) {
+	b.WriteString("<ul>")
+	for _, h := range headings {
+		b.WriteString(`<li><a href="#`)
+		b.WriteString(template.HTMLEscapeString(h.ID))
+		b.WriteString(`">`)
+		b.WriteString(template.HTMLEscapeString(h.Title))
+		b.WriteString("</a>")
+		if len(h.Children) > 0 {
+			writeTOCList(b, h.Children)
+		}
+		b.WriteString("</li>")
+	}
+	b.WriteString("</ul>")
+}
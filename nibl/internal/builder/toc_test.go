@@ -0,0 +1,85 @@
+// internal/builder/toc_test.go
+package builder
+
+import (
+	"nibl/internal/config"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestHeadingIDs(t *testing.T) {
+	md := newMarkdownRenderer(config.SiteConfig{})
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{name: "ascii", src: "# The Garden", want: []string{"the-garden"}},
+		{name: "punctuation dropped", src: "## What's next? (part 2)", want: []string{"whats-next-part-2"}},
+		{name: "accents kept", src: "# Capítulo uno", want: []string{"capítulo-uno"}},
+		{name: "other scripts", src: "# Введение\n\n## 第一章", want: []string{"введение", "第一章"}},
+		{name: "duplicates numbered", src: "## Notes\n\n## Notes\n\n## Notes", want: []string{"notes", "notes-1", "notes-2"}},
+		{name: "empty text", src: "## ???", want: []string{"heading"}},
+		{name: "digits and underscores", src: "## 2024 snake_case", want: []string{"2024-snake-case"}},
+		{name: "with footnotes", src: "## Opening\n\nText.[^1]\n\n[^1]: A note.", want: []string{"opening"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The ids must also survive the sanitizer.
+			content, err := processContent(md, []byte(tt.src), basePolicy(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, h := range content.headings {
+				got = append(got, h.ID)
+				if n := strings.Count(content.html, ` id="`+h.ID+`"`); n != 1 {
+					t.Errorf("id %q appears %d times in %s", h.ID, n, content.html)
+				}
+			}
+			// No element may carry an attribute twice, e.g. footnote ids.
+			z := html.NewTokenizer(strings.NewReader(content.html))
+			for z.Next() != html.ErrorToken {
+				seen := make(map[string]bool)
+				for _, attr := range z.Token().Attr {
+					if seen[attr.Key] {
+						t.Errorf("duplicate %s attribute in %s", attr.Key, content.html)
+					}
+					seen[attr.Key] = true
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got ids %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadingTree(t *testing.T) {
+	headings := []*Heading{
+		{Level: 2, ID: "a", Title: "A"},
+		{Level: 3, ID: "a1", Title: "A1"},
+		{Level: 4, ID: "a1x", Title: "A1x"},
+		{Level: 5, ID: "deep", Title: "Deep"},
+		{Level: 2, ID: "b", Title: "B & C"},
+		{Level: 4, ID: "b-skip", Title: "Skipped level"},
+	}
+	tests := []struct {
+		depth int
+		want  string
+	}{
+		{depth: 0, want: `<nav class="toc"><ul><li><a href="#a">A</a><ul><li><a href="#a1">A1</a><ul><li><a href="#a1x">A1x</a></li></ul></li></ul></li><li><a href="#b">B &amp; C</a><ul><li><a href="#b-skip">Skipped level</a></li></ul></li></ul></nav>`},
+		{depth: 1, want: `<nav class="toc"><ul><li><a href="#a">A</a></li><li><a href="#b">B &amp; C</a></li></ul></nav>`},
+		{depth: 2, want: `<nav class="toc"><ul><li><a href="#a">A</a><ul><li><a href="#a1">A1</a></li></ul></li><li><a href="#b">B &amp; C</a></li></ul></nav>`},
+	}
+	for _, tt := range tests {
+		if got := string(renderTOC(headingTree(headings, tt.depth))); got != tt.want {
+			t.Errorf("depth %d:\n got %s\nwant %s", tt.depth, got, tt.want)
+		}
+	}
+	if got := renderTOC(headingTree(nil, 3)); got != "" {
+		t.Errorf("a page without headings got %q", got)
+	}
+}
@@ -16,6 +16,106 @@ type SiteConfig struct {
 	BaseURL     string `yaml:"baseurl"`
 	Description string `yaml:"description"`
 	Template    string `yaml:"template"`
+
+	// Taxonomies lists the front matter keys that group content, e.g.
+	// [tags, characters]. Each gets a term list page and one page per term.
+	Taxonomies []string `yaml:"taxonomies"`
+
+	// Permalinks maps a content section to a URL pattern, e.g.
+	// {chapters: ":section/:year/:slug"}. See builder.expandPermalink.
+	Permalinks map[string]string `yaml:"permalinks"`
+	// PrettyURLs writes pages as slug/index.html and links them as slug/.
+	PrettyURLs bool `yaml:"pretty_urls"`
+	// AlwaysPublish lists content paths that are published even when marked
+	// `draft: true`, e.g. [index, about.md, "legal/*"]. The extension is
+	// optional. Unset, it is [index, about, menu]; set [] to exempt nothing.
+	AlwaysPublish []string `yaml:"always_publish"`
+	// SummaryLength is the number of words in the summary of a page without
+	// a <!--more--> divider, 70 if unset.
+	SummaryLength int `yaml:"summary_length"`
+	// Languages makes the site multilingual. The first language is the
+	// default and is published at the site root; the others below their code.
+	Languages []LanguageConfig `yaml:"languages"`
+
+	Feeds     FeedConfig      `yaml:"feeds"`
+	Sitemap   SitemapConfig   `yaml:"sitemap"`
+	Highlight HighlightConfig `yaml:"highlight"`
+	Static    StaticConfig    `yaml:"static"`
+	Assets    AssetConfig     `yaml:"assets"`
+	Images    ImageConfig     `yaml:"images"`
+	Sanitize  SanitizeConfig  `yaml:"sanitize"`
+}
+
+// FeedConfig controls the RSS and Atom feeds written by `nibl gen`.
+type FeedConfig struct {
+	Disabled bool `yaml:"disabled"`
+	Limit    int  `yaml:"limit"` // Maximum entries per feed, 0 for all
+}
+
+// SitemapConfig controls sitemap.xml and the generated robots.txt.
+type SitemapConfig struct {
+	Disabled bool `yaml:"disabled"`
+	// ExcludeVariants leaves out the state-variant pages of a story, so
+	// search engines only index each knot's canonical page.
+	ExcludeVariants bool `yaml:"exclude_variants"`
+}
+
+// HighlightConfig controls syntax highlighting of fenced code blocks. Code
+// is marked up with CSS classes; the colours come from a generated stylesheet.
+type HighlightConfig struct {
+	Disabled    bool   `yaml:"disabled"`
+	Style       string `yaml:"style"`        // Chroma style name, "github" if empty
+	LineNumbers bool   `yaml:"line_numbers"` // Number the lines of every block
+	Stylesheet  string `yaml:"stylesheet"`   // Output path, "css/highlight.css" if empty
+}
+
+// StaticConfig selects the files copied from static/. Everything is copied
+// unless Include is set; Exclude always wins. Patterns without a slash
+// match any file or directory name (e.g. "*.psd", "drafts"); patterns with
+// a slash match the path below static/ and may use ** (e.g. "audio/**/*.wav").
+type StaticConfig struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+// AssetConfig switches on the asset pipeline for the CSS and JS files in
+// static/ and for the generated HTML. Templates link assets with
+// {{ asset "css/style.css" }} so fingerprinted names are picked up.
+type AssetConfig struct {
+	Minify      bool `yaml:"minify"`      // Minify CSS, JS and generated HTML
+	Fingerprint bool `yaml:"fingerprint"` // Add a content hash to CSS and JS file names
+	Integrity   bool `yaml:"integrity"`   // Provide Subresource Integrity hashes
+}
+
+// ImageConfig sets the defaults for images processed by templates with
+// {{ image "images/map.jpg" }} and {{ srcset $ $img }}.
+type ImageConfig struct {
+	Quality int   `yaml:"quality"` // JPEG quality from 1 to 100, 80 if unset
+	Widths  []int `yaml:"widths"`  // srcset widths, [480, 800, 1200] if unset
+}
+
+// SanitizeConfig extends the HTML allowed in content, which is otherwise
+// limited to bluemonday's UGC policy. For example:
+//
+//	sanitize:
+//	  elements: [figure, figcaption, details, summary]
+//	  attributes:
+//	    audio: [src, controls]
+//	    "*": [class]
+//	  schemes: [tel]
+type SanitizeConfig struct {
+	Elements   []string            `yaml:"elements"`   // Elements allowed without attributes
+	Attributes map[string][]string `yaml:"attributes"` // Attributes allowed per element, "*" for any element
+	Schemes    []string            `yaml:"schemes"`    // URL schemes allowed besides http, https and mailto
+}
+
+// LanguageConfig declares one language of a multilingual site. Its content
+// lives in content/<code>/ or in files named like chapter.<code>.md.
+type LanguageConfig struct {
+	Code        string `yaml:"code"`        // e.g. "es"
+	Name        string `yaml:"name"`        // Shown in language menus, e.g. "Español"
+	Title       string `yaml:"title"`       // Site title in this language, if different
+	Description string `yaml:"description"` // Site description in this language, if different
 }
 
 // LoadSiteConfig now uses a proper YAML parser for robust and safe config loading.
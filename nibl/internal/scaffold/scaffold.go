@@ -4,6 +4,7 @@ package scaffold
 import (
 	"bytes"
 	"fmt"
+	"nibl/internal/builder"
 	"nibl/internal/config"
 	"os"
 	"path/filepath"
@@ -18,7 +19,7 @@ func CreateNewSite(name string) error {
 	writeFile := func(path, content string) error {
 		return os.WriteFile(filepath.Join(name, path), []byte(content), 0644)
 	}
-	dirs := []string{"content", "static/css", "static/js", "static/images", "templates/simple", "archetypes"}
+	dirs := []string{"content", "static/css", "static/js", "static/images", "templates/simple/shortcodes", "archetypes"}
 	for _, dir := range dirs {
 		if err := mkdir(dir); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
@@ -26,13 +27,16 @@ func CreateNewSite(name string) error {
 	}
 
 	files := map[string]string{
-		"site.yaml":                     siteYamlContent,
-		"site.biff":                     siteBiffContent,
-		"static/css/style.css":          staticCssContent,
-		"templates/simple/layout.html":  templateLayoutHtmlContent,
-		"templates/simple/header.html":  templateHeaderHtmlContent,
-		"templates/simple/footer.html":  templateFooterHtmlContent,
-		"archetypes/default.md":         archetypeDefaultMdContent,
+		"site.yaml":                               siteYamlContent,
+		"site.biff":                               siteBiffContent,
+		"static/css/style.css":                    staticCssContent,
+		"templates/simple/layout.html":            templateLayoutHtmlContent,
+		"templates/simple/header.html":            templateHeaderHtmlContent,
+		"templates/simple/footer.html":            templateFooterHtmlContent,
+		"templates/simple/list.html":              templateListHtmlContent,
+		"templates/simple/404.html":               template404HtmlContent,
+		"templates/simple/shortcodes/figure.html": templateFigureShortcodeContent,
+		"archetypes/default.md":                   archetypeDefaultMdContent,
 	}
 	for path, content := range files {
 		if err := writeFile(path, content); err != nil {
@@ -65,7 +69,7 @@ func CreateNewContent(contentType, title, configPath string) error {
 		return fmt.Errorf("could not read archetype file %s: %w", archetypePath, err)
 	}
 
-	tmpl, err := template.New("archetype").Parse(string(tmplBytes))
+	tmpl, err := template.New("archetype").Funcs(builder.ArchetypeFuncs(site)).Parse(string(tmplBytes))
 	if err != nil {
 		return fmt.Errorf("failed to parse archetype file %s: %w", archetypePath, err)
 	}
@@ -97,6 +101,7 @@ author: Your Name
 baseurl: /
 description: A new story powered by nibl.
 template: simple
+taxonomies: [tags, characters]
 `
 const siteBiffContent = `// title: My Enchanted Garden
 // author: A. Writer 
@@ -155,14 +160,29 @@ footer nav a:hover { text-decoration: underline; }
 ul { margin-left: 1.2em; padding-left: 1.2em; list-style-type: disc; }
 li { margin-bottom: 0.25em; }
 hr { border: none; border-top: 1px solid #ccc; width: 33%; margin: 2em auto; }
+.draft-banner { background: #fff3cd; color: #856404; text-align: center; padding: 0.5em; margin-bottom: 1em; }
+.draft-label { font-size: 0.8em; color: #856404; font-style: italic; }
+.toc { font-size: 0.9em; border-left: 2px solid #ccc; padding-left: 1em; margin-bottom: 2em; }
+.summary { font-size: 0.9em; color: #555; margin: 0.25em 0 0.75em; }
+.summary p { margin: 0; }
+.page-nav { display: flex; justify-content: space-between; margin-top: 2em; }
+.page-nav a { color: #444; text-decoration: none; }
+.page-nav a[rel=next] { margin-left: auto; }
+.toc ul { list-style-type: none; margin-left: 0; padding-left: 1em; }
 `
 const templateLayoutHtmlContent = `{{ define "main" }}
 <!DOCTYPE html>
-<html>
+<html{{ with .Site.Language.Code }} lang="{{ . }}"{{ end }}>
 <head>
   <meta charset="utf-8">
   <title>{{ .Title }} | {{ if .StoryTitle }}{{ .StoryTitle }}{{ else }}{{ .Site.Title }}{{ end }}</title>
-  <link rel="stylesheet" href="{{ .BaseHref }}css/style.css">
+  <link rel="stylesheet" href="{{ relURL $ (asset "css/style.css") }}"{{ integrity "css/style.css" }}>
+{{ with .Site.HighlightCSS }}
+  <link rel="stylesheet" href="{{ $.BaseHref }}{{ . }}">
+{{ end }}
+{{ if .Site.HasFeed }}
+  <link rel="alternate" type="application/rss+xml" title="{{ .Site.Title }}" href="{{ .BaseHref }}{{ .Site.Language.Dir }}index.xml">
+{{ end }}
 {{ if .Description }}
   <meta name="description" content="{{ .Description }}">
 {{ else }}
@@ -180,7 +200,58 @@ const templateLayoutHtmlContent = `{{ define "main" }}
 <body>
   {{ template "header" . }}
   <main>
+    {{ if .Draft }}<p class="draft-banner">Draft &mdash; not published without --drafts</p>{{ end }}
+    {{ if .Params.toc }}{{ .TableOfContents }}{{ end }}
     {{ .Content }}
+    {{ if or .Prev .Next }}
+    <nav class="page-nav">
+      {{ with .Prev }}<a href="{{ $.BaseHref }}{{ .URL }}" rel="prev">&larr; {{ .Title }}</a>{{ end }}
+      {{ with .Next }}<a href="{{ $.BaseHref }}{{ .URL }}" rel="next">{{ .Title }} &rarr;</a>{{ end }}
+    </nav>
+    {{ end }}
+  </main>
+  {{ template "footer" . }}
+</body>
+</html>
+{{ end }}`
+
+const templateListHtmlContent = `{{ define "list" }}
+<!DOCTYPE html>
+<html{{ with .Site.Language.Code }} lang="{{ . }}"{{ end }}>
+<head>
+  <meta charset="utf-8">
+  <title>{{ .Title }} | {{ if .StoryTitle }}{{ .StoryTitle }}{{ else }}{{ .Site.Title }}{{ end }}</title>
+  <link rel="stylesheet" href="{{ relURL $ (asset "css/style.css") }}"{{ integrity "css/style.css" }}>
+  <meta name="description" content="{{ .Description }}">
+</head>
+<body>
+  {{ template "header" . }}
+  <main>
+    <h1>{{ .Title }}</h1>
+    <ul class="page-list">
+    {{ range .Pages }}
+      <li><a href="{{ $.BaseHref }}{{ .URL }}">{{ .Title }}</a>{{ if .Draft }} <span class="draft-label">(draft)</span>{{ end }}{{ if .Description }} &mdash; {{ .Description }}{{ else if .Summary }}<div class="summary">{{ summary $ . }}</div>{{ end }}</li>
+    {{ end }}
+    </ul>
+  </main>
+  {{ template "footer" . }}
+</body>
+</html>
+{{ end }}`
+
+const template404HtmlContent = `{{ define "main" }}
+<!DOCTYPE html>
+<html{{ with .Site.Language.Code }} lang="{{ . }}"{{ end }}>
+<head>
+  <meta charset="utf-8">
+  <title>{{ .Title }} | {{ .Site.Title }}</title>
+  <link rel="stylesheet" href="{{ relURL $ (asset "css/style.css") }}"{{ integrity "css/style.css" }}>
+</head>
+<body>
+  {{ template "header" . }}
+  <main>
+    <h1>{{ .Title }}</h1>
+    <p>There is nothing here. The page may have been renamed; try the <a href="{{ .BaseHref }}index.html">beginning</a>.</p>
   </main>
   {{ template "footer" . }}
 </body>
@@ -202,7 +273,8 @@ const templateHeaderHtmlContent = `{{ define "header" }}
 const templateFooterHtmlContent = `{{ define "footer" }}
 <footer>
   <nav>
-    <a href="{{ .BaseHref }}index.html">home</a>
+    <a href="{{ .BaseHref }}{{ .Site.Language.Dir }}index.html">{{ i18n "home" }}</a>
+    {{ range .Translations }}<a href="{{ $.BaseHref }}{{ .URL }}" hreflang="{{ .Language.Code }}">{{ .Language.Name }}</a>{{ end }}
   </nav>
   <div class="copyright">
     &copy; {{ .Site.Title }}
@@ -210,3 +282,10 @@ const templateFooterHtmlContent = `{{ define "footer" }}
 </footer>
 {{ end }}`
 
+const templateFigureShortcodeContent = `{{ $img := image (.Get "src") }}{{ $large := $img.Resize 1200 -}}
+<figure>
+  <img src="{{ relURL .Page $large.URL }}" srcset="{{ srcset .Page $img }}" sizes="(max-width: 800px) 100vw, 800px"
+       width="{{ $large.Width }}" height="{{ $large.Height }}" alt="{{ .Get "alt" }}">
+  {{ with .Get "caption" }}<figcaption>{{ markdownify . }}</figcaption>{{ end }}
+</figure>
+`
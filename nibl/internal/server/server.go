@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"nibl/internal/builder"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -84,7 +85,7 @@ func Run(port int, buildFunc func(builder.BuildOptions) error, opts builder.Buil
 	})
 
 	fileServer := http.FileServer(http.Dir("public"))
-	mux.Handle("/", liveReloadWrapper(fileServer))
+	mux.Handle("/", liveReloadWrapper(notFoundWrapper("public", fileServer)))
 
 	addr := fmt.Sprintf(":%d", port)
 	fmt.Printf("Serving site on http://localhost%s\n", addr)
@@ -102,6 +103,10 @@ func watchForChanges(watcher *fsnotify.Watcher, hub *Hub, buildFunc func(builder
 			if !ok {
 				return
 			}
+			// The image cache sits next to site.yaml; creating it is not an edit.
+			if opts.CacheDir != "" && filepath.Clean(event.Name) == filepath.Clean(opts.CacheDir) {
+				continue
+			}
 			// We now get notifications for create, write, remove, and rename
 			// to robustly handle all editor save strategies.
 			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
@@ -127,6 +132,23 @@ func watchForChanges(watcher *fsnotify.Watcher, hub *Hub, buildFunc func(builder
 	}
 }
 
+// notFoundWrapper answers requests for missing files with the site's
+// 404.html, when it has one, as a static host would.
+func notFoundWrapper(root string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(root, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			if page, err := os.ReadFile(filepath.Join(root, "404.html")); err == nil {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(http.StatusNotFound)
+				w.Write(page)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func liveReloadWrapper(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -56,9 +56,20 @@ func preParseBiffForFrontMatter(biffData []byte) (map[string]map[string]string,
 	return data, nil
 }
 
+// shortcodeTag matches shortcodes such as {{< figure src="a.jpg" >}} in a
+// knot body. They are rendered by the site builder, so EditML must not see them.
+var shortcodeTag = regexp.MustCompile(`(?s)\{\{<.*?>\}\}`)
+
 // processKnotContent is the central function for handling a knot's body.
 // It correctly processes EditML syntax and returns clean markdown ready for rendering.
 func processKnotContent(rawContent string) (string, error) {
+	// Set shortcodes aside while EditML runs and put them back verbatim.
+	var shortcodes []string
+	rawContent = shortcodeTag.ReplaceAllStringFunc(rawContent, func(tag string) string {
+		shortcodes = append(shortcodes, tag)
+		return fmt.Sprintf("NIBLKNOTSHORTCODE%dEND", len(shortcodes)-1)
+	})
+
 	nodes, parseIssues := editml.Parse(rawContent)
 	if len(parseIssues) > 0 && parseIssues[0].Severity == editml.SeverityError {
 		return "", fmt.Errorf("editml parsing error: %s", parseIssues[0].Message)
@@ -67,6 +78,10 @@ func processKnotContent(rawContent string) (string, error) {
 	if len(transformIssues) > 0 && transformIssues[0].Severity == editml.SeverityError {
 		return "", fmt.Errorf("editml transformation error: %s", transformIssues[0].Message)
 	}
+
+	for i, tag := range shortcodes {
+		cleanMarkdown = strings.Replace(cleanMarkdown, fmt.Sprintf("NIBLKNOTSHORTCODE%dEND", i), tag, 1)
+	}
 	return cleanMarkdown, nil
 }
 
@@ -157,7 +172,7 @@ func Compile(biffPath, contentDir string, siteCfg config.SiteConfig) (int, error
 			return 0, fmt.Errorf("failed to process content for knot %s: %w", node.KnotName, err)
 		}
 
-		writeFrontMatter(file, &intermediate.Metadata, displayTitle, knotMeta)
+		writeFrontMatter(file, &intermediate.Metadata, displayTitle, knotMeta, siteCfg.Taxonomies, isStateVariant(node))
 
 		fmt.Fprintf(file, "## %s\n\n", displayTitle)
 		fmt.Fprintln(file, finalPageContent)
@@ -177,8 +192,11 @@ func Compile(biffPath, contentDir string, siteCfg config.SiteConfig) (int, error
 	return filesWritten, nil
 }
 
-// writeFrontMatter writes the YAML front matter to the file.
-func writeFrontMatter(f *os.File, storyMeta *map[string]string, displayTitle string, knotMeta map[string]string) {
+// writeFrontMatter writes the YAML front matter to the file. Knot comments
+// naming a taxonomy, e.g. `// characters: Mara, Tomas`, are written as lists,
+// as are old URLs in `// aliases: garden, old/garden.html`.
+// State-variant knots are marked so the builder can tell them from canonical ones.
+func writeFrontMatter(f *os.File, storyMeta *map[string]string, displayTitle string, knotMeta map[string]string, taxonomies []string, variant bool) {
 	fmt.Fprintln(f, "---")
 	fmt.Fprintf(f, "title: \"%s\"\n", strings.ReplaceAll(displayTitle, "\"", "\\\""))
 
@@ -189,16 +207,57 @@ func writeFrontMatter(f *os.File, storyMeta *map[string]string, displayTitle str
 		fmt.Fprintf(f, "story_author: \"%s\"\n", strings.ReplaceAll(sa, "\"", "\\\""))
 	}
 
-	for key, value := range knotMeta {
+	// Keys are written in sorted order so that recompiling an unchanged story
+	// produces byte-identical files, which keeps incremental builds effective.
+	keys := make([]string, 0, len(knotMeta))
+	for key := range knotMeta {
 		if key != "title" {
-			fmt.Fprintf(f, "%s: \"%s\"\n", key, strings.ReplaceAll(value, "\"", "\\\""))
+			keys = append(keys, key)
 		}
 	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "aliases" || isTaxonomy(key, taxonomies) {
+			var terms []string
+			for _, term := range strings.Split(knotMeta[key], ",") {
+				if term = strings.TrimSpace(term); term != "" {
+					terms = append(terms, "\""+strings.ReplaceAll(term, "\"", "\\\"")+"\"")
+				}
+			}
+			fmt.Fprintf(f, "%s: [%s]\n", key, strings.Join(terms, ", "))
+			continue
+		}
+		fmt.Fprintf(f, "%s: \"%s\"\n", key, strings.ReplaceAll(knotMeta[key], "\"", "\\\""))
+	}
 
+	if variant {
+		fmt.Fprintln(f, "state_variant: true")
+	}
 	fmt.Fprintln(f, "draft: false")
 	fmt.Fprintln(f, "---")
 }
 
+// isStateVariant reports whether a node is a knot rendered under active
+// state flags, i.e. one whose path carries flag suffixes (see buildPaths).
+func isStateVariant(node *bigif.StoryNode) bool {
+	for _, v := range node.State {
+		if v {
+			return true
+		}
+	}
+	return false
+}
+
+// isTaxonomy reports whether a knot comment key names a configured taxonomy.
+func isTaxonomy(key string, taxonomies []string) bool {
+	for _, name := range taxonomies {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
 func buildPaths(nodes map[string]*bigif.StoryNode, outDir string) map[string]string {
 	paths := make(map[string]string)
 	for id, node := range nodes {
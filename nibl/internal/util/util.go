@@ -2,8 +2,10 @@ package util
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -20,7 +22,9 @@ func Check(err error) {
 
 // ComputeBaseHref calculates the relative path to the site root
 // so that CSS/JS links work correctly for pages at any depth.
-// For example, a page at /posts/a/b.html would get a BaseHref of "../../".
+// relPath is the page's output path, which permalinks may have moved
+// away from its source path. For example, a page written to
+// posts/a/b.html (or posts/a/b/index.html) gets "../../" (or "../../../").
 func ComputeBaseHref(relPath string) string {
 	dir := filepath.Dir(relPath)
 	if dir == "." {
@@ -30,3 +34,30 @@ func ComputeBaseHref(relPath string) string {
 	return strings.Repeat("../", depth)
 }
 
+// Slugify turns arbitrary text into a lowercase, URL-safe path segment.
+// For example, "Mara's Garden" becomes "maras-garden".
+func Slugify(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = slugStrip.ReplaceAllString(s, "")
+	s = slugSpace.ReplaceAllString(s, "-")
+	return strings.Trim(s, "-")
+}
+
+var (
+	slugStrip = regexp.MustCompile(`[^\p{L}\p{N}_\s-]+`)
+	slugSpace = regexp.MustCompile(`[\s_-]+`)
+)
+
+// IsAbsoluteURL reports whether u has a scheme and host, e.g. "https://example.com/".
+func IsAbsoluteURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
+
+// AbsURL joins the site's base URL and a path relative to the site root.
+// For example, ("https://example.com/stories/", "ch1/a.html") becomes
+// "https://example.com/stories/ch1/a.html".
+func AbsURL(baseURL, rel string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(rel, "/")
+}
+